@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/MasteryConnect/pipe/message"
+	"github.com/MasteryConnect/pipe/trace"
 )
 
 // Do will execute incoming http.Request message.
@@ -32,14 +33,14 @@ func (d Do) T(in <-chan interface{}, out chan<- interface{}, errs chan<- error)
 		// get the request to send
 		switch v := m.(type) {
 		case *http.Request:
-			resp, err := cl.Do(v)
+			resp, err := do(cl, v)
 			if err != nil {
 				errs <- err
 			} else {
 				out <- resp
 			}
 		case message.Requester:
-			resp, err := cl.Do(v.Request())
+			resp, err := do(cl, v.Request())
 			if err != nil {
 				errs <- err
 			} else {
@@ -48,3 +49,20 @@ func (d Do) T(in <-chan interface{}, out chan<- interface{}, errs chan<- error)
 		}
 	}
 }
+
+// do sends the request and records a span if the request is being traced.
+func do(cl *http.Client, req *http.Request) (*http.Response, error) {
+	if _, traced := trace.SpanFrom(req.Context()); !traced {
+		return cl.Do(req)
+	}
+
+	ctx, span := trace.Start(req.Context(), "http.Do")
+	span.SetAttr("method", req.Method)
+	span.SetAttr("url", req.URL.String())
+	resp, err := cl.Do(req.WithContext(ctx))
+	if resp != nil {
+		span.SetAttr("status", resp.StatusCode)
+	}
+	span.End(err)
+	return resp, err
+}
@@ -1,9 +1,13 @@
 package http
 
 import (
+	"context"
 	"net/http"
+	"net/http/httptest"
+	"testing"
 
 	l "github.com/MasteryConnect/pipe/line"
+	"github.com/MasteryConnect/pipe/trace"
 )
 
 func ExampleDo_T() {
@@ -27,3 +31,41 @@ func ExampleDo_T() {
 	// 200 OK
 	// 200 OK
 }
+
+func TestDo_trace(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer srv.Close()
+
+	mem := &trace.MemoryExporter{}
+
+	l.New().SetPContext(func(ctx context.Context, out chan<- interface{}, errs chan<- error) {
+		traced := trace.Begin(ctx, nil)
+		req, err := http.NewRequest("GET", srv.URL, nil)
+		if err != nil {
+			errs <- err
+			return
+		}
+		out <- req.WithContext(traced.Ctx)
+	}).Add(
+		Do{}.T,
+		l.Noop,
+	).(l.Instrumenter).SetTracer(mem).Run()
+
+	spans := mem.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("want 3 spans got %d: %+v", len(spans), spans)
+	}
+
+	do, stage, next := spans[0], spans[1], spans[2]
+	if do.Name != "http.Do" || do.Attrs["status"] != http.StatusTeapot {
+		t.Errorf("unexpected http.Do span %+v", do)
+	}
+	if do.ParentID != stage.SpanID {
+		t.Errorf("want the http.Do span in the stage span got %+v", do)
+	}
+	if next.ParentID != do.SpanID || next.TraceID != do.TraceID {
+		t.Errorf("want the response to carry the trace on got %+v", next)
+	}
+}
@@ -8,13 +8,15 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+
+	"github.com/MasteryConnect/pipe/message"
 )
 
-// From converts the json message to a map string interface
+// From converts the json message to a *message.BasicRecord (or a slice
+// or value if that is what the json holds). The objects are converted to
+// *message.BasicRecord so the key order of the json is kept.
 func From(msg interface{}) (interface{}, error) {
-	var val interface{}
-	err := json.Unmarshal([]byte(msg.(fmt.Stringer).String()), &val)
-	return val, err
+	return message.FromJSON([]byte(msg.(fmt.Stringer).String()))
 }
 
 // FromAs converts the json message to an instance of the type of the passed pointer
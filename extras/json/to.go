@@ -6,14 +6,15 @@ import (
 	"github.com/MasteryConnect/pipe/message"
 )
 
-// To converts the message to a json message
+// To converts the message to a json message.
+// Records are converted to JSON objects with the keys in order.
 func To(msg interface{}) (interface{}, error) {
 	var err error
 	var b []byte
 
 	switch v := msg.(type) {
 	case message.Record:
-		b, err = json.Marshal(message.RecordToMSI(v))
+		b, err = message.RecordToJSON(v)
 	default:
 		b, err = json.Marshal(msg)
 	}
@@ -0,0 +1,19 @@
+package json_test
+
+import (
+	"fmt"
+
+	"github.com/MasteryConnect/pipe/extras/json"
+	"github.com/MasteryConnect/pipe/message"
+)
+
+func ExampleFrom() {
+	rec, _ := json.From(&message.Bytes{B: []byte(`{"z":1,"a":{"c":3,"b":2}}`)})
+	fmt.Println(rec.(message.Record).GetKeys())
+
+	b, _ := json.To(rec)
+	fmt.Println(b)
+	// Output:
+	// [z a]
+	// {"z":1,"a":{"c":3,"b":2}}
+}
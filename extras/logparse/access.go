@@ -0,0 +1,79 @@
+package logparse
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+// the NCSA common log format with the referer and user agent of the combined format being optional
+var accessLogRe = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "((?:[^"\\]|\\.)*)" (\d{3}|-) (\d+|-)( "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?\s*$`)
+
+const accessLogLayout = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLog parses the common and combined log formats of Apache and Nginx.
+// The fields are remote_addr, ident, user, request, method, path, protocol,
+// status and bytes plus referer and user_agent for the combined format.
+// Fields that are - are left out.
+type AccessLog struct{}
+
+// Parse implements the Format interface
+func (AccessLog) Parse(line string) (time.Time, *message.BasicRecord, error) {
+	m := accessLogRe.FindStringSubmatch(line)
+	if m == nil {
+		return time.Time{}, nil, errors.New("not an access log line")
+	}
+	ts, err := time.Parse(accessLogLayout, m[4])
+	if err != nil {
+		return ts, nil, err
+	}
+
+	r := message.NewBasicRecord()
+	set := func(key, val string) {
+		if val != "-" {
+			r.Set(key, val)
+		}
+	}
+	set("remote_addr", m[1])
+	set("ident", m[2])
+	set("user", m[3])
+
+	req := unescape(m[5])
+	set("request", req)
+	if parts := strings.Fields(req); len(parts) == 3 {
+		r.Set("method", parts[0])
+		r.Set("path", parts[1])
+		r.Set("protocol", parts[2])
+	}
+
+	for i, key := range []string{"status", "bytes"} {
+		if n, err := strconv.Atoi(m[6+i]); err == nil {
+			r.Set(key, n)
+		}
+	}
+
+	if m[8] != "" { // combined
+		set("referer", unescape(m[9]))
+		set("user_agent", unescape(m[10]))
+	}
+	return ts, r, nil
+}
+
+// unescape removes the \ escapes Apache and Nginx add to quoted fields.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
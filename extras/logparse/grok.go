@@ -0,0 +1,159 @@
+package logparse
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+// GrokPatterns are the named patterns a Grok pattern can use.
+var GrokPatterns = map[string]string{
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"INT":               `[+-]?\d+`,
+	"POSINT":            `\b[1-9]\d*\b`,
+	"NUMBER":            `[+-]?(?:\d+(?:\.\d+)?|\.\d+)`,
+	"IPV4":              `(?:\d{1,3}\.){3}\d{1,3}`,
+	"IPV6":              `[0-9A-Fa-f]*:[0-9A-Fa-f:.]+`,
+	"IP":                `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":          `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?\b`,
+	"IPORHOST":          `(?:%{IP}|%{HOSTNAME})`,
+	"USER":              `[a-zA-Z0-9._-]+`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"PATH":              `(?:/[^\s/]*)+`,
+	"QS":                `"(?:[^"\\]|\\.)*"`,
+	"LOGLEVEL":          `(?i:trace|debug|info|notice|warn(?:ing)?|err(?:or)?|crit(?:ical)?|fatal|severe|emerg(?:ency)?|alert)`,
+	"TIMESTAMP_ISO8601": `\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?(?:Z|[+-]\d{2}:?\d{2})?`,
+	"HTTPDATE":          `\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`,
+	"SYSLOGTIMESTAMP":   `\w{3} [ \d]\d \d{2}:\d{2}:\d{2}`,
+}
+
+var grokRe = regexp.MustCompile(`%\{(\w+)(?::([\w.@-]+))?(?::(int|float))?\}`)
+
+// Grok parses lines with a regular expression that can use named patterns
+// like %{NAME}, %{NAME:field} to set a field to what it matched or
+// %{NAME:field:int} (or float) to convert it as well. Named groups like
+// (?P<field>...) set a field too. Like grok the pattern only has to match
+// part of the line unless it is anchored with ^ and $. Make one with NewGrok.
+type Grok struct {
+	TimestampFields []string // the fields tried for the time (time, ts, timestamp and @timestamp by default)
+	Layouts         []string // the layouts tried for the time (RFC 3339 and a few others by default)
+
+	re     *regexp.Regexp
+	fields []grokField  // the fields of the named patterns
+	groups []*grokField // the field of each capture group if it has one
+}
+
+type grokField struct {
+	name string
+	typ  string
+}
+
+// NewGrok compiles the pattern with the patterns in GrokPatterns and custom
+// which can also use named patterns.
+func NewGrok(pattern string, custom map[string]string) (*Grok, error) {
+	g := &Grok{}
+	expr, err := g.expand(pattern, custom, 0)
+	if err != nil {
+		return nil, err
+	}
+	if g.re, err = regexp.Compile(expr); err != nil {
+		return nil, err
+	}
+
+	g.groups = make([]*grokField, len(g.re.SubexpNames()))
+	for i, name := range g.re.SubexpNames() {
+		if n, err := strconv.Atoi(strings.TrimPrefix(name, "_")); err == nil && strings.HasPrefix(name, "_") {
+			g.groups[i] = &g.fields[n]
+		} else if name != "" {
+			g.groups[i] = &grokField{name: name}
+		}
+	}
+	return g, nil
+}
+
+// MustGrok is like NewGrok but panics if the pattern can't be compiled.
+func MustGrok(pattern string, custom map[string]string) *Grok {
+	g, err := NewGrok(pattern, custom)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// expand replaces the named patterns with their expressions
+// and the ones with a field with a capture group.
+func (g *Grok) expand(pattern string, custom map[string]string, depth int) (string, error) {
+	if depth > 10 {
+		return "", fmt.Errorf("logparse: grok patterns nest too deep in %q", pattern)
+	}
+
+	var err error
+	expr := grokRe.ReplaceAllStringFunc(pattern, func(m string) string {
+		sub := grokRe.FindStringSubmatch(m)
+		p, ok := custom[sub[1]]
+		if !ok {
+			p, ok = GrokPatterns[sub[1]]
+		}
+		if !ok {
+			err = fmt.Errorf("logparse: unknown grok pattern %s", sub[1])
+			return ""
+		}
+		inner, e := g.expand(p, custom, depth+1)
+		if e != nil {
+			err = e
+			return ""
+		}
+		if sub[2] == "" {
+			return "(?:" + inner + ")"
+		}
+		g.fields = append(g.fields, grokField{name: sub[2], typ: sub[3]})
+		return fmt.Sprintf("(?P<_%d>%s)", len(g.fields)-1, inner)
+	})
+	return expr, err
+}
+
+// Parse implements the Format interface
+func (g *Grok) Parse(line string) (time.Time, *message.BasicRecord, error) {
+	if g.re == nil {
+		return time.Time{}, nil, fmt.Errorf("logparse: Grok has no pattern (use NewGrok)")
+	}
+	m := g.re.FindStringSubmatchIndex(line)
+	if m == nil {
+		return time.Time{}, nil, fmt.Errorf("doesn't match %s", g.re)
+	}
+
+	r := message.NewBasicRecord()
+	for i, f := range g.groups {
+		if f == nil || m[2*i] < 0 {
+			continue // not a field or it didn't match
+		}
+		val := line[m[2*i]:m[2*i+1]]
+		switch f.typ {
+		case "int":
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return time.Time{}, nil, fmt.Errorf("%s isn't an int: %v", f.name, err)
+			}
+			r.Set(f.name, n)
+		case "float":
+			n, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return time.Time{}, nil, fmt.Errorf("%s isn't a float: %v", f.name, err)
+			}
+			r.Set(f.name, n)
+		default:
+			r.Set(f.name, val)
+		}
+	}
+
+	ts, err := timestamp(r, g.TimestampFields, g.Layouts)
+	return ts, r, err
+}
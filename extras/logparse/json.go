@@ -0,0 +1,30 @@
+package logparse
+
+import (
+	"errors"
+	"time"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+// JSON parses lines of JSON objects keeping the order of the keys. Nested
+// objects are a *message.BasicRecord as well.
+type JSON struct {
+	TimestampFields []string // the fields tried for the time (time, ts, timestamp and @timestamp by default)
+	Layouts         []string // the layouts tried for the time (RFC 3339 and a few others by default)
+}
+
+// Parse implements the Format interface
+func (j JSON) Parse(line string) (time.Time, *message.BasicRecord, error) {
+	v, err := message.FromJSON([]byte(line))
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	r, ok := v.(*message.BasicRecord)
+	if !ok {
+		return time.Time{}, nil, errors.New("not a JSON object")
+	}
+
+	ts, err := timestamp(r, j.TimestampFields, j.Layouts)
+	return ts, r, err
+}
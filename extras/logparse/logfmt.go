@@ -0,0 +1,62 @@
+package logparse
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+// Logfmt parses key=value lines. Values can be quoted with \" and \\ escaped
+// and keys without a value are set to true. The values are kept as strings.
+type Logfmt struct {
+	TimestampFields []string // the fields tried for the time (time, ts, timestamp and @timestamp by default)
+	Layouts         []string // the layouts tried for the time (RFC 3339 and a few others by default)
+}
+
+// Parse implements the Format interface
+func (l Logfmt) Parse(line string) (time.Time, *message.BasicRecord, error) {
+	r := message.NewBasicRecord()
+	s := strings.TrimSpace(line)
+	for s != "" {
+		end := strings.IndexAny(s, "= ")
+		if end < 0 {
+			end = len(s)
+		}
+		if end == 0 {
+			return time.Time{}, nil, errors.New("missing logfmt key")
+		}
+		key := s[:end]
+		s = s[end:]
+
+		if !strings.HasPrefix(s, "=") {
+			r.Set(key, true)
+		} else if s = s[1:]; strings.HasPrefix(s, `"`) {
+			val, n, ok := unquote(s, `"\`)
+			if !ok {
+				return time.Time{}, nil, errors.New("unterminated logfmt value of " + key)
+			}
+			r.Set(key, val)
+			s = s[n:]
+		} else {
+			sp := strings.IndexByte(s, ' ')
+			if sp < 0 {
+				sp = len(s)
+			}
+			r.Set(key, s[:sp])
+			s = s[sp:]
+		}
+
+		if s != "" && s[0] != ' ' {
+			return time.Time{}, nil, errors.New("invalid logfmt after " + key)
+		}
+		s = strings.TrimLeft(s, " ")
+	}
+	if len(r.Keys) == 0 {
+		return time.Time{}, nil, errors.New("empty logfmt line")
+	}
+
+	ts, err := timestamp(r, l.TimestampFields, l.Layouts)
+	return ts, r, err
+}
@@ -0,0 +1,183 @@
+// Package logparse parses common log line formats into message.Event messages.
+package logparse
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+// Format parses a log line into its time and fields. The time is zero
+// if the line doesn't have one.
+type Format interface {
+	Parse(line string) (time.Time, *message.BasicRecord, error)
+}
+
+// Line is a log line with where it came from. Other messages are used
+// as the text of a line numbered in the order they are received.
+type Line struct {
+	Source string // the file or stream of the line
+	Num    int    // the line number starting at 1
+	Text   string
+}
+
+// String implements the fmt.Stringer interface
+func (l Line) String() string {
+	return l.Text
+}
+
+// ParseError is the error for a line that can't be parsed.
+type ParseError struct {
+	Source string
+	Line   int
+	Text   string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	if e.Source != "" {
+		return fmt.Sprintf("logparse: %s:%d: %v", e.Source, e.Line, e.Err)
+	}
+	return fmt.Sprintf("logparse: line %d: %v", e.Line, e.Err)
+}
+
+// Cause returns the error parsing the line.
+func (e *ParseError) Cause() error {
+	return e.Err
+}
+
+// Parse parses log lines into message.Event messages with the fields
+// of the line as a *message.BasicRecord in the Message. Lines that can't
+// be parsed are sent to errs as a *ParseError.
+type Parse struct {
+	Format Format
+	Source interface{} // the Source of the events unless a Line has its own
+}
+
+// T is the Tfunc for a pipe/line.
+func (p Parse) T(in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
+	num := 0
+	for m := range in {
+		num++
+		l, ok := m.(Line)
+		if !ok {
+			l = Line{Num: num, Text: message.String(m)}
+		}
+		e, err := p.parse(l)
+		if err != nil {
+			errs <- err
+		} else {
+			out <- e
+		}
+	}
+}
+
+// I is the Ifunc for a pipe/line. Only a Line knows its line number here.
+func (p Parse) I(m interface{}) (interface{}, error) {
+	l, ok := m.(Line)
+	if !ok {
+		l = Line{Text: message.String(m)}
+	}
+	return p.parse(l)
+}
+
+func (p Parse) parse(l Line) (message.Event, error) {
+	ts, r, err := p.Format.Parse(l.Text)
+	if err != nil {
+		return message.Event{}, &ParseError{Source: l.Source, Line: l.Num, Text: l.Text, Err: err}
+	}
+	e := message.Event{Timestamp: ts, Source: p.Source, Message: r}
+	if l.Source != "" {
+		e.Source = l.Source
+	}
+	return e, nil
+}
+
+// the fields and layouts tried for the time of JSON and logfmt lines by default
+var (
+	defaultTimestampFields = []string{"time", "ts", "timestamp", "@timestamp"}
+	defaultLayouts         = []string{
+		time.RFC3339Nano,
+		"2006-01-02 15:04:05Z07:00",
+		"2006-01-02 15:04:05",
+		"02/Jan/2006:15:04:05 -0700",
+	}
+)
+
+// timestamp gets the time from the first of the fields the record has.
+// Numbers are taken as seconds since the epoch or milliseconds if too
+// big to be seconds.
+func timestamp(r message.Record, fields, layouts []string) (time.Time, error) {
+	if len(fields) == 0 {
+		fields = defaultTimestampFields
+	}
+	if len(layouts) == 0 {
+		layouts = defaultLayouts
+	}
+
+	for _, f := range fields {
+		v, ok := r.Get(f)
+		if !ok || v == nil {
+			continue
+		}
+		switch val := v.(type) {
+		case float64:
+			return epoch(val), nil
+		case int:
+			return epoch(float64(val)), nil
+		case int64:
+			return epoch(float64(val)), nil
+		case string:
+			if n, err := strconv.ParseFloat(val, 64); err == nil {
+				return epoch(n), nil
+			}
+			return parseTime(val, layouts)
+		}
+		return time.Time{}, fmt.Errorf("%s is a %T not a time", f, v)
+	}
+	return time.Time{}, nil
+}
+
+func epoch(n float64) time.Time {
+	if n >= 1e11 {
+		n /= 1000 // milliseconds
+	}
+	sec := math.Floor(n)
+	usec := math.Round((n - sec) * 1e6) // floats don't have the precision for nanoseconds
+	return time.Unix(int64(sec), int64(usec)*1000).UTC()
+}
+
+// parseTime tries each layout in turn.
+func parseTime(s string, layouts []string) (time.Time, error) {
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("can't parse the time %q", s)
+}
+
+// unquote reads the quoted string at the start of s where a \ escapes
+// the chars in escapable. It returns the string and how much of s it used.
+func unquote(s, escapable string) (string, int, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", 0, false
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			return b.String(), i + 1, true
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(escapable, s[i+1]) >= 0:
+			i++
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, false
+}
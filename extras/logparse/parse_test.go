@@ -0,0 +1,131 @@
+package logparse_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MasteryConnect/pipe/extras/logparse"
+	"github.com/MasteryConnect/pipe/line"
+	"github.com/MasteryConnect/pipe/message"
+)
+
+func ExampleParse_T() {
+	line.New().SetP(func(out chan<- interface{}, errs chan<- error) {
+		out <- `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`
+	}).Add(
+		logparse.Parse{Format: logparse.AccessLog{}, Source: "access.log"}.T,
+		line.Stdout,
+	).Run()
+
+	// Output: 2000-10-10T13:55:36-07:00 access.log {"remote_addr":"127.0.0.1","user":"frank","request":"GET /apache_pb.gif HTTP/1.0","method":"GET","path":"/apache_pb.gif","protocol":"HTTP/1.0","status":200,"bytes":2326}
+}
+
+// fields gets the fields of the record as key=value pairs in order.
+func fields(r *message.BasicRecord) string {
+	var kv []string
+	for i, k := range r.Keys {
+		kv = append(kv, fmt.Sprintf("%s=%v", k, message.String(r.Vals[i])))
+	}
+	return strings.Join(kv, " ")
+}
+
+func TestFormats(t *testing.T) {
+	now := func() time.Time { return time.Date(2021, 1, 5, 0, 0, 0, 0, time.UTC) }
+	grok := logparse.MustGrok(`^%{TIMESTAMP_ISO8601:time} \[%{LOGLEVEL:level}\] %{WORD:user} took %{NUMBER:ms:float}ms(?: id=(?P<id>%{INT}))?`, nil)
+
+	cases := []struct {
+		format logparse.Format
+		line   string
+		ts     time.Time
+		fields string
+	}{
+		{logparse.Syslog{}, `<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed for lonvick on /dev/pts/8`,
+			time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC),
+			"facility=4 severity=2 version=1 hostname=mymachine.example.com app_name=su msgid=ID47 message='su root' failed for lonvick on /dev/pts/8"},
+		{logparse.Syslog{}, `<165>1 2003-10-11T22:14:15Z host evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="App\"lication"][examplePriority@32473 class="high"] An application event`,
+			time.Date(2003, 10, 11, 22, 14, 15, 0, time.UTC),
+			`facility=20 severity=5 version=1 hostname=host app_name=evntslog msgid=ID47 structured_data={"exampleSDID@32473":{"iut":"3","eventSource":"App\"lication"},"examplePriority@32473":{"class":"high"}} message=An application event`},
+		{logparse.Syslog{Now: now}, `<13>Dec 30 08:01:02 myhost sshd[4321]: Accepted publickey for bob`,
+			time.Date(2020, 12, 30, 8, 1, 2, 0, time.UTC),
+			"facility=1 severity=5 hostname=myhost app_name=sshd procid=4321 message=Accepted publickey for bob"},
+		{logparse.Syslog{Now: now}, `Jan  4 10:00:00 myhost kernel message`,
+			time.Date(2021, 1, 4, 10, 0, 0, 0, time.UTC),
+			"hostname=myhost message=kernel message"},
+		{logparse.AccessLog{}, `10.0.0.1 - - [10/Oct/2000:13:55:36 +0000] "GET /a?b=\"c\" HTTP/1.1" 404 - "http://example.com/" "Mozilla/5.0 (X11)"`,
+			time.Date(2000, 10, 10, 13, 55, 36, 0, time.UTC),
+			`remote_addr=10.0.0.1 request=GET /a?b="c" HTTP/1.1 method=GET path=/a?b="c" protocol=HTTP/1.1 status=404 referer=http://example.com/ user_agent=Mozilla/5.0 (X11)`},
+		{logparse.Logfmt{}, `ts=2020-01-02T03:04:05Z level=info msg="hello \"world\"" debug`,
+			time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			`ts=2020-01-02T03:04:05Z level=info msg=hello "world" debug=true`},
+		{logparse.JSON{TimestampFields: []string{"at"}}, `{"at":1577934245.5,"msg":"hi","ctx":{"b":1,"a":2}}`,
+			time.Date(2020, 1, 2, 3, 4, 5, 500000000, time.UTC),
+			`at=1.5779342455e+09 msg=hi ctx={"b":1,"a":2}`},
+		{logparse.JSON{}, `{"@timestamp":"2020-01-02 03:04:05","msg":"hi"}`,
+			time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			`@timestamp=2020-01-02 03:04:05 msg=hi`},
+		{grok, `2020-01-02T03:04:05Z [WARN] bob took 12.5ms id=7`,
+			time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			`time=2020-01-02T03:04:05Z level=WARN user=bob ms=12.5 id=7`},
+	}
+	for _, c := range cases {
+		ts, r, err := c.format.Parse(c.line)
+		if err != nil {
+			t.Errorf("%T: %v parsing %s", c.format, err, c.line)
+			continue
+		}
+		if !ts.Equal(c.ts) {
+			t.Errorf("%T: want the time %v got %v", c.format, c.ts, ts)
+		}
+		if got := fields(r); got != c.fields {
+			t.Errorf("%T:\nwant %s\n got %s", c.format, c.fields, got)
+		}
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	in := make(chan interface{}, 3)
+	out := make(chan interface{}, 3)
+	errs := make(chan error, 3)
+	in <- "a=1"
+	in <- "=oops"
+	in <- logparse.Line{Source: "app.log", Num: 12, Text: `a="open`}
+	close(in)
+
+	logparse.Parse{Format: logparse.Logfmt{}}.T(in, out, errs)
+	close(errs)
+
+	if len(out) != 1 {
+		t.Errorf("expected 1 event got %d", len(out))
+	}
+	var got []string
+	for err := range errs {
+		var perr *logparse.ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("expected a *ParseError got %T", err)
+		}
+		got = append(got, err.Error())
+	}
+	want := []string{
+		"logparse: line 2: missing logfmt key",
+		"logparse: app.log:12: unterminated logfmt value of a",
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %q got %q", want, got)
+	}
+}
+
+func TestNewGrok_errors(t *testing.T) {
+	if _, err := logparse.NewGrok(`%{NOPE:x}`, nil); err == nil {
+		t.Error("expected an error for an unknown pattern")
+	}
+	if _, err := logparse.NewGrok(`%{A}`, map[string]string{"A": "%{B}", "B": "%{A}"}); err == nil {
+		t.Error("expected an error for patterns that nest forever")
+	}
+	if _, _, err := (&logparse.Grok{}).Parse("hi"); err == nil {
+		t.Error("expected an error for a Grok without a pattern")
+	}
+}
@@ -0,0 +1,182 @@
+package logparse
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+// Syslog parses RFC 5424 and RFC 3164 (BSD) syslog lines. The version after
+// the priority tells them apart. The fields are facility, severity, hostname,
+// app_name, procid and message plus version, msgid and structured_data for
+// RFC 5424. The structured data is a record of a record of params per SD-ID.
+// Fields with the nil value (-) are left out.
+type Syslog struct {
+	// Location is the time zone of RFC 3164 times which don't have one (UTC by default).
+	Location *time.Location
+	// Now is used to get the year of RFC 3164 times (time.Now by default).
+	Now func() time.Time
+}
+
+var errSyslog = errors.New("not a syslog line")
+
+// Parse implements the Format interface
+func (s Syslog) Parse(line string) (time.Time, *message.BasicRecord, error) {
+	r := message.NewBasicRecord()
+	rest := line
+	if strings.HasPrefix(rest, "<") {
+		end := strings.IndexByte(rest, '>')
+		if end < 2 || end > 4 {
+			return time.Time{}, nil, errors.New("invalid syslog priority")
+		}
+		pri, err := strconv.Atoi(rest[1:end])
+		if err != nil || pri > 191 {
+			return time.Time{}, nil, errors.New("invalid syslog priority")
+		}
+		r.Set("facility", pri/8)
+		r.Set("severity", pri%8)
+		rest = rest[end+1:]
+
+		// RFC 5424 has a version straight after the priority
+		if len(rest) > 1 && rest[0] >= '1' && rest[0] <= '9' {
+			if sp := strings.IndexByte(rest, ' '); sp > 0 {
+				if v, err := strconv.Atoi(rest[:sp]); err == nil {
+					r.Set("version", v)
+					return s.parse5424(r, rest[sp+1:])
+				}
+			}
+		}
+	}
+	return s.parse3164(r, rest)
+}
+
+// parse5424 parses TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]
+func (s Syslog) parse5424(r *message.BasicRecord, rest string) (time.Time, *message.BasicRecord, error) {
+	var ts time.Time
+	parts := strings.SplitN(rest, " ", 6)
+	if len(parts) < 6 {
+		return ts, nil, errSyslog
+	}
+	if parts[0] != "-" {
+		var err error
+		if ts, err = time.Parse(time.RFC3339Nano, parts[0]); err != nil {
+			return ts, nil, err
+		}
+	}
+	for i, f := range []string{"hostname", "app_name", "procid", "msgid"} {
+		if parts[i+1] != "-" {
+			r.Set(f, parts[i+1])
+		}
+	}
+
+	sd, msg, err := structuredData(parts[5])
+	if err != nil {
+		return ts, nil, err
+	}
+	if sd != nil {
+		r.Set("structured_data", sd)
+	}
+	if msg != "" {
+		r.Set("message", strings.TrimPrefix(msg, "\ufeff"))
+	}
+	return ts, r, nil
+}
+
+// structuredData parses the [SD-ID param="value" ...] elements and returns the rest as the message.
+func structuredData(s string) (*message.BasicRecord, string, error) {
+	if strings.HasPrefix(s, "-") {
+		return nil, strings.TrimPrefix(s[1:], " "), nil
+	}
+
+	sd := message.NewBasicRecord()
+	for strings.HasPrefix(s, "[") {
+		end := strings.IndexAny(s, " ]")
+		if end < 0 {
+			return nil, "", errors.New("unterminated structured data")
+		}
+		params := message.NewBasicRecord()
+		sd.Set(s[1:end], params)
+		s = s[end:]
+
+		for strings.HasPrefix(s, " ") {
+			s = s[1:]
+			eq := strings.Index(s, `="`)
+			if eq < 1 {
+				return nil, "", errors.New("invalid structured data param")
+			}
+			name := s[:eq]
+			val, n, ok := unquote(s[eq+1:], `\"]`)
+			if !ok {
+				return nil, "", errors.New("unterminated structured data param")
+			}
+			params.Set(name, val)
+			s = s[eq+1+n:]
+		}
+		if !strings.HasPrefix(s, "]") {
+			return nil, "", errors.New("unterminated structured data")
+		}
+		s = s[1:]
+	}
+	if s != "" && !strings.HasPrefix(s, " ") {
+		return nil, "", errors.New("invalid structured data")
+	}
+	return sd, strings.TrimPrefix(s, " "), nil
+}
+
+// parse3164 parses Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+func (s Syslog) parse3164(r *message.BasicRecord, rest string) (time.Time, *message.BasicRecord, error) {
+	if len(rest) < len(time.Stamp)+1 || rest[len(time.Stamp)] != ' ' {
+		return time.Time{}, nil, errSyslog
+	}
+	loc := s.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	ts, err := time.ParseInLocation(time.Stamp, rest[:len(time.Stamp)], loc)
+	if err != nil {
+		return ts, nil, err
+	}
+
+	// the year isn't in the line so it is the current one unless that is in the future
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	n := now().In(loc)
+	ts = ts.AddDate(n.Year(), 0, 0)
+	if ts.After(n.AddDate(0, 0, 1)) {
+		ts = ts.AddDate(-1, 0, 0)
+	}
+
+	rest = rest[len(time.Stamp)+1:]
+	sp := strings.IndexByte(rest, ' ')
+	if sp < 1 {
+		return ts, nil, errSyslog
+	}
+	r.Set("hostname", rest[:sp])
+	rest = rest[sp+1:]
+
+	// the tag is optional so it is only taken if the first word ends with a colon
+	word := rest
+	if sp := strings.IndexByte(rest, ' '); sp >= 0 {
+		word = rest[:sp]
+	}
+	if len(word) > 1 && strings.HasSuffix(word, ":") {
+		tag, pid := word[:len(word)-1], ""
+		if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+			tag, pid = tag[:open], tag[open+1:len(tag)-1]
+		}
+		r.Set("app_name", tag)
+		if pid != "" {
+			r.Set("procid", pid)
+		}
+		rest = strings.TrimPrefix(rest[len(word):], " ")
+	}
+	if rest != "" {
+		r.Set("message", rest)
+	}
+	return ts, r, nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/MasteryConnect/pipe/message"
+	"github.com/MasteryConnect/pipe/trace"
 	"github.com/pkg/errors"
 )
 
@@ -39,7 +40,11 @@ func (m Exec) T(in <-chan interface{}, out chan<- interface{}, errs chan<- error
 			errs <- err
 		}
 		if result != nil {
-			out <- message.SQLResult{Result: result.(dbsql.Result)}
+			res := message.SQLResult{Result: result.(dbsql.Result)}
+			if c, ok := msg.(message.ContextGetter); ok {
+				res.Context = c.GetContext() // keep the context (and trace) with the result
+			}
+			out <- res
 		}
 	}
 }
@@ -49,10 +54,22 @@ func (m Exec) T(in <-chan interface{}, out chan<- interface{}, errs chan<- error
 func (m Exec) I(msg interface{}) (interface{}, error) {
 	switch v := msg.(type) {
 	case message.ToSQLer:
-		sql, args := v.ToSQL()
+		sql, args, err := message.BuildSQL(v)
+		if err != nil {
+			return nil, err
+		}
 		if c, cok := msg.(message.ContextGetter); cok {
 			ctx := c.GetContext()
 			if ctx != nil {
+				// record a span if the query is being traced
+				if _, traced := trace.SpanFrom(ctx); traced {
+					var span *trace.ActiveSpan
+					ctx, span = trace.Start(ctx, "sql.Exec")
+					span.SetAttr("sql", sql)
+					result, err := m.DB.ExecContext(ctx, sql, args...)
+					span.End(err)
+					return result, err
+				}
 				return m.DB.ExecContext(ctx, sql, args...)
 			}
 		}
@@ -56,7 +56,10 @@ func (m Query) T(in <-chan interface{}, out chan<- interface{}, errs chan<- erro
 func (m Query) I(msg interface{}) (interface{}, error) {
 	switch v := msg.(type) {
 	case message.ToSQLer:
-		sql, args := v.ToSQL()
+		sql, args, err := message.BuildSQL(v)
+		if err != nil {
+			return nil, err
+		}
 		return m.DB.Queryx(sql, args...)
 	default:
 		return m.DB.Queryx(message.String(v))
@@ -102,3 +102,50 @@ func TestQuery(t *testing.T) {
 		t.Errorf("want %v got %v", wantAges, ages)
 	}
 }
+
+func TestQuery_decode(t *testing.T) {
+	db, err := sqlx.Open("ramsql", "TestQuery_decode")
+	if err != nil {
+		t.Fatalf("ramsql.Open: Error: %s\n", err)
+	}
+	defer db.Close()
+
+	for _, step := range []string{
+		"CREATE TABLE users (id BIGSERIAL PRIMARY KEY, name TEXT, age INT);",
+		"INSERT INTO users (name,age) VALUES ('alice',30);",
+	} {
+		if _, err = db.Exec(step); err != nil {
+			t.Fatalf("ramsql.Exec: Error: %s\n", err)
+		}
+	}
+
+	type user struct {
+		Name string `db:"name"`
+		Age  int    `db:"age"`
+	}
+
+	resultsI, err := sql.Query{DB: db}.I("SELECT name,age FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := resultsI.(*sqlx.Rows)
+	defer results.Close()
+
+	var users []user
+	sql.ExtractRecordsFromRows(results, func(row message.OrderedRecord, err error) error {
+		var u user
+		if err == nil {
+			err = message.Decode(row, &u, "db")
+		}
+		if err != nil {
+			t.Error(err)
+		}
+		users = append(users, u)
+		return nil
+	})
+
+	want := []user{{Name: "alice", Age: 30}}
+	if !reflect.DeepEqual(want, users) {
+		t.Errorf("want %v got %v", want, users)
+	}
+}
@@ -0,0 +1,130 @@
+package line
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Controller is a handle to a pipeline started with Start.
+// It can pause, resume, cancel and wait on the running pipeline.
+type Controller struct {
+	l    *Line
+	r    *run
+	done chan struct{}
+	err  error
+}
+
+// StageStats holds the message counts for a single stage of a running pipeline.
+type StageStats struct {
+	Name string
+	In   int64 // messages received from upstream
+	Out  int64 // messages sent downstream
+}
+
+// Status is a snapshot of a running pipeline.
+type Status struct {
+	Paused bool
+	Done   bool
+	Err    error // the error the pipeline finished with if done
+	Stages []StageStats
+}
+
+// String implements the fmt.Stringer interface
+func (s Status) String() string {
+	state := "running"
+	switch {
+	case s.Done && s.Err != nil:
+		state = fmt.Sprintf("done (err: %v)", s.Err)
+	case s.Done:
+		state = "done"
+	case s.Paused:
+		state = "paused"
+	}
+
+	var b strings.Builder
+	b.WriteString(state)
+	for _, st := range s.Stages {
+		fmt.Fprintf(&b, "\n  %s: in %d out %d", st.Name, st.In, st.Out)
+	}
+	return b.String()
+}
+
+// Start runs the whole pipeline in the background and returns a Controller for it.
+func (l *Line) Start() *Controller {
+	return l.StartContext(context.Background())
+}
+
+// StartContext is Start with a context.Context.
+func (l *Line) StartContext(ctx context.Context) *Controller {
+	ctx, cancel := context.WithCancel(ctx)
+
+	r := l.newRun(cancel, true)
+	r.gate = &gate{}
+
+	c := &Controller{l: l, r: r, done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		defer cancel()
+		c.err = l.execute(ctx, r)
+	}()
+	return c
+}
+
+// Pause stops the producer from sending any more messages into the pipeline.
+// Messages already in the pipeline continue on through.
+func (c *Controller) Pause() {
+	c.r.gate.pause()
+}
+
+// Resume lets the producer send messages into the pipeline again.
+func (c *Controller) Resume() {
+	// reset the movement so a watchdog doesn't count the pause as a stall
+	for _, lk := range c.r.links {
+		lk.touch()
+	}
+	c.r.gate.open()
+}
+
+// Cancel stops the pipeline. Messages in the pipeline are dropped
+// and Wait will return context.Canceled.
+func (c *Controller) Cancel() {
+	c.r.abort(context.Canceled)
+}
+
+// Wait blocks until the pipeline is done and returns the error it finished with.
+func (c *Controller) Wait() error {
+	<-c.done
+	return c.err
+}
+
+// Status gets a snapshot of the pipeline.
+func (c *Controller) Status() Status {
+	s := Status{Paused: c.r.gate.paused()}
+	select {
+	case <-c.done:
+		s.Done = true
+		s.Err = c.err
+	default:
+	}
+
+	s.Stages = c.r.stats()
+	return s
+}
+
+// stats gets the message counts of each stage from the links.
+func (r *run) stats() []StageStats {
+	var stages []StageStats
+	for i, st := range r.stages {
+		stats := StageStats{Name: st.name}
+		if i > 0 {
+			stats.In = atomic.LoadInt64(&r.links[i-1].count)
+		}
+		if i < len(r.links) {
+			stats.Out = atomic.LoadInt64(&r.links[i].count)
+		}
+		stages = append(stages, stats)
+	}
+	return stages
+}
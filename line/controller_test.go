@@ -0,0 +1,85 @@
+package line_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	l "github.com/MasteryConnect/pipe/line"
+)
+
+func TestController_pause(t *testing.T) {
+	var produced int64
+	got := 0
+
+	c := l.New().
+		SetP(func(out chan<- interface{}, errs chan<- error) {
+			for i := 0; i < 100; i++ {
+				out <- i
+				atomic.AddInt64(&produced, 1)
+			}
+		}).
+		Add(l.Noop).
+		SetC(func(in <-chan interface{}, errs chan<- error) {
+			for range in {
+				got++
+			}
+		}).(l.Starter).
+		Start()
+
+	c.Pause()
+	time.Sleep(10 * time.Millisecond)
+	paused := atomic.LoadInt64(&produced)
+	time.Sleep(10 * time.Millisecond)
+
+	if atomic.LoadInt64(&produced) != paused {
+		t.Errorf("producer kept going while paused: %d then %d", paused, atomic.LoadInt64(&produced))
+	}
+
+	status := c.Status()
+	if !status.Paused || status.Done {
+		t.Errorf("want paused got %s", status)
+	}
+
+	c.Resume()
+	if err := c.Wait(); err != nil {
+		t.Error(err)
+	}
+
+	if got != 100 {
+		t.Errorf("want 100 messages got %d", got)
+	}
+
+	status = c.Status()
+	if !status.Done {
+		t.Errorf("want done got %s", status)
+	}
+	if len(status.Stages) != 3 {
+		t.Fatalf("want 3 stages got %d", len(status.Stages))
+	}
+	if status.Stages[1].In != 100 || status.Stages[1].Out != 100 {
+		t.Errorf("want 100 in and out got %s", status)
+	}
+}
+
+func TestController_cancel(t *testing.T) {
+	c := l.New().
+		SetPContext(func(ctx context.Context, out chan<- interface{}, errs chan<- error) {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case out <- "foo":
+				}
+			}
+		}).(l.Starter).
+		Start()
+
+	time.Sleep(time.Millisecond)
+	c.Cancel()
+
+	if err := c.Wait(); err != context.Canceled {
+		t.Errorf("want context.Canceled got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package line
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Node kinds
+const (
+	KindPipeline    = "pipeline"
+	KindProducer    = "producer"
+	KindTransformer = "transformer"
+	KindConsumer    = "consumer"
+)
+
+// Node describes a stage of a pipeline. Composite stages (like a Tee or an
+// embedded pipeline) have the stages they run as Children.
+type Node struct {
+	Name        string
+	Kind        string
+	Concurrency int  // number of go routines running the stage (0 if unknown)
+	Buffer      int  // size of the buffer the stage holds messages in
+	Parallel    bool // the children run side by side instead of one after the other
+	Children    []Node
+
+	Stats *StageStats // the run-time counts if described from a Controller
+}
+
+// Describer is implemented by stages that can describe themselves.
+// Composite stages should implement it to include their nested stages.
+type Describer interface {
+	Describe() Node
+}
+
+// Describe describes any stage. Describers describe themselves and
+// anything else is described by its type or func name.
+func Describe(v interface{}) Node {
+	var n Node
+	if d, ok := v.(Describer); ok {
+		n = d.Describe()
+	} else {
+		n = Node{Name: typeName(v), Concurrency: 1}
+	}
+	if n.Kind == "" {
+		n.Kind = KindTransformer
+	}
+	return n
+}
+
+// Describe describes the whole pipeline with each step as a child.
+func (l *Line) Describe() Node {
+	n := Node{Name: "line.Line", Kind: KindPipeline, Concurrency: 1}
+
+	producer := Node{Kind: KindProducer, Concurrency: 1}
+	if l.pContext != nil {
+		producer.Name = funcName(l.pContext)
+	} else {
+		producer.Name = funcName(l.p)
+	}
+	n.Children = append(n.Children, producer)
+
+	for _, t := range l.t {
+		n.Children = append(n.Children, t.describe())
+	}
+
+	n.Children = append(n.Children, Node{Name: funcName(l.c), Kind: KindConsumer, Concurrency: 1})
+	return n
+}
+
+// T implements the Transformer interface by embedding the pipeline.
+// This lets a pipeline be added to another with AddTransformer.
+func (l *Line) T(in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
+	l.Embed(in, out, errs)
+}
+
+// Describe describes the running pipeline with the run-time
+// counts of each stage.
+func (c *Controller) Describe() Node {
+	return c.l.describeRun(c.r)
+}
+
+// describeRun describes the pipeline with the counts of each stage of the run.
+func (l *Line) describeRun(r *run) Node {
+	n := l.Describe()
+	stages := r.stats()
+	for i := range n.Children {
+		if i < len(stages) {
+			stats := stages[i]
+			n.Children[i].Stats = &stats
+		}
+	}
+	return n
+}
+
+// label is the details of the node on one line.
+func (n Node) label() string {
+	parts := []string{n.Name}
+	if n.Concurrency > 1 {
+		parts = append(parts, fmt.Sprintf("concurrency %d", n.Concurrency))
+	}
+	if n.Buffer > 0 {
+		parts = append(parts, fmt.Sprintf("buffer %d", n.Buffer))
+	}
+	if n.Stats != nil {
+		parts = append(parts, fmt.Sprintf("in %d out %d", n.Stats.In, n.Stats.Out))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Text renders the node and its children as an indented tree.
+func (n Node) Text() string {
+	var b strings.Builder
+	n.text(&b, 0)
+	return b.String()
+}
+
+func (n Node) text(b *strings.Builder, depth int) {
+	fmt.Fprintf(b, "%s%s (%s)\n", strings.Repeat("  ", depth), n.label(), n.Kind)
+	for _, c := range n.Children {
+		c.text(b, depth+1)
+	}
+}
+
+// DOT renders the node and its children as a Graphviz DOT graph.
+func (n Node) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph pipeline {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	id := 0
+	n.dot(&b, &id, "  ")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dot writes the node and returns the ids of the first and last
+// graph nodes so the caller can connect the edges.
+func (n Node) dot(b *strings.Builder, id *int, indent string) (first, last string) {
+	*id++
+	self := fmt.Sprintf("n%d", *id)
+
+	if len(n.Children) == 0 {
+		fmt.Fprintf(b, "%s%s [label=%q];\n", indent, self, n.label())
+		return self, self
+	}
+
+	fmt.Fprintf(b, "%ssubgraph cluster_%s {\n", indent, self)
+	fmt.Fprintf(b, "%s  label=%q;\n", indent, n.label())
+
+	if n.Parallel {
+		// fan out from the node to each child and back in again
+		fmt.Fprintf(b, "%s  %s [label=%q, shape=point];\n", indent, self, n.Name)
+		join := self + "_join"
+		fmt.Fprintf(b, "%s  %s [label=\"\", shape=point];\n", indent, join)
+		for _, c := range n.Children {
+			cf, cl := c.dot(b, id, indent+"  ")
+			fmt.Fprintf(b, "%s  %s -> %s;\n", indent, self, cf)
+			fmt.Fprintf(b, "%s  %s -> %s;\n", indent, cl, join)
+		}
+		fmt.Fprintf(b, "%s}\n", indent)
+		return self, join
+	}
+
+	// chain the children one after the other
+	var prev string
+	var prevStats *StageStats
+	for _, c := range n.Children {
+		cf, cl := c.dot(b, id, indent+"  ")
+		if first == "" {
+			first = cf
+		}
+		if prev != "" {
+			if prevStats != nil {
+				fmt.Fprintf(b, "%s  %s -> %s [label=\"%d\"];\n", indent, prev, cf, prevStats.Out)
+			} else {
+				fmt.Fprintf(b, "%s  %s -> %s;\n", indent, prev, cf)
+			}
+		}
+		prev = cl
+		prevStats = c.Stats
+	}
+	fmt.Fprintf(b, "%s}\n", indent)
+	return first, prev
+}
@@ -0,0 +1,56 @@
+package line_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	l "github.com/MasteryConnect/pipe/line"
+)
+
+func ExampleLine_Describe() {
+	sub := l.New().Add(l.Noop)
+
+	p := l.New().
+		Add(l.Noop).(l.TransformerAdder).
+		AddTransformer(sub.(*l.Line)).
+		SetC(l.NoopC)
+
+	fmt.Print(l.Describe(p).Text())
+	// Output:
+	// line.Line (pipeline)
+	//   line.Stdin (producer)
+	//   line.Noop (transformer)
+	//   line.Line (pipeline)
+	//     line.Stdin (producer)
+	//     line.Noop (transformer)
+	//     line.Consumer (consumer)
+	//   line.NoopC (consumer)
+}
+
+func TestController_Describe(t *testing.T) {
+	c := l.New().
+		SetP(func(out chan<- interface{}, errs chan<- error) {
+			for i := 0; i < 3; i++ {
+				out <- i
+			}
+		}).
+		Add(l.Noop).(l.Starter).
+		Start()
+	c.Wait()
+
+	n := c.Describe()
+	if len(n.Children) != 3 {
+		t.Fatalf("want 3 children got %d", len(n.Children))
+	}
+	if n.Children[1].Stats == nil || n.Children[1].Stats.In != 3 {
+		t.Errorf("want the stats overlaid got %+v", n.Children[1].Stats)
+	}
+
+	dot := n.DOT()
+	for _, want := range []string{"digraph pipeline {", `label="line.Noop, in 3 out 3"`, `[label="3"]`} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("want %s in:\n%s", want, dot)
+		}
+	}
+}
@@ -1,25 +1,104 @@
 package line
 
+import "fmt"
+
+// EmbedOpts configures how a pipeline is embedded in a parent pipeline.
+type EmbedOpts struct {
+	// Name identifies the sub-pipeline in the EmbedResult message.
+	Name string
+
+	// HandleErr is called with each error raised inside the sub-pipeline.
+	// The returned error is sent on to the parent's errs channel.
+	// Returning nil swallows the error so it can be handled locally.
+	HandleErr func(error) error
+
+	// Result will send an *EmbedResult message downstream to the parent
+	// once the sub-pipeline has finished.
+	Result bool
+}
+
+// EmbedResult is the message sent to the parent pipeline when an embedded
+// pipeline finishes and EmbedOpts.Result is set.
+type EmbedResult struct {
+	Name string
+	In   int   // number of messages sent in to the sub-pipeline
+	Out  int   // number of messages sent back out to the parent
+	Errs int   // number of errors raised inside the sub-pipeline
+	Err  error // the first error raised inside the sub-pipeline
+}
+
+// Failed returns true if the sub-pipeline raised any errors.
+func (r *EmbedResult) Failed() bool {
+	return r.Errs > 0
+}
+
+// String implements the fmt.Stringer interface
+func (r *EmbedResult) String() string {
+	if r.Failed() {
+		return fmt.Sprintf("%s: in %d out %d failed with %d errors (first: %v)", r.Name, r.In, r.Out, r.Errs, r.Err)
+	}
+	return fmt.Sprintf("%s: in %d out %d ok", r.Name, r.In, r.Out)
+}
+
 // Embed runs the whole pipeline as a transformer of a parent pipeline.
+// Errors are passed on to the parent's errs channel.
 func (l *Line) Embed(parentIn <-chan interface{}, parentOut chan<- interface{}, parentErrs chan<- error) {
-	embedP := func(out chan<- interface{}, errs chan<- error) {
-		for msg := range parentIn {
-			out <- msg
+	l.EmbedWith(EmbedOpts{})(parentIn, parentOut, parentErrs)
+}
+
+// EmbedWith returns a Tfunc that runs the whole pipeline as a transformer
+// of a parent pipeline. The pipeline definition isn't changed so the
+// returned Tfunc can be used as many times as needed.
+func (l *Line) EmbedWith(opts EmbedOpts) Tfunc {
+	return func(parentIn <-chan interface{}, parentOut chan<- interface{}, parentErrs chan<- error) {
+		res := &EmbedResult{Name: opts.Name}
+
+		// work on a copy so the original definition is left untouched
+		child := *l
+		child.t = append([]tfuncEnum{}, l.t...)
+		child.pContext = nil
+		child.p = func(out chan<- interface{}, errs chan<- error) {
+			for msg := range parentIn {
+				res.In++
+				out <- msg
+			}
 		}
-	}
-	embedC := func(in <-chan interface{}, errs chan<- error) {
-		for msg := range in {
-			parentOut <- msg
+		child.c = func(in <-chan interface{}, errs chan<- error) {
+			for msg := range in {
+				res.Out++
+				parentOut <- msg
+			}
 		}
-	}
 
-	l.p = embedP
-	l.c = embedC
+		// give the sub-pipeline its own error scope
+		errs := make(chan error)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for err := range errs {
+				res.Errs++
+				if res.Err == nil {
+					res.Err = err
+				}
+				if opts.HandleErr != nil {
+					err = opts.HandleErr(err)
+				}
+				if err != nil {
+					parentErrs <- err
+				}
+			}
+		}()
+		child.errs = errs
 
-	l.SetErrs(parentErrs)
+		if err := child.Run(); err != nil {
+			errs <- err
+		}
 
-	err := l.Run()
-	if err != nil {
-		parentErrs <- err
+		close(errs)
+		<-done
+
+		if opts.Result {
+			parentOut <- res
+		}
 	}
 }
@@ -91,3 +91,79 @@ func TestPipeline_Embed(t *testing.T) {
 		t.Errorf("error: want 'foo error' got '%s'", err.Error())
 	}
 }
+
+func TestPipeline_EmbedWith(t *testing.T) {
+	sub := l.New().
+		Add(
+			l.Inline(func(m interface{}) (interface{}, error) {
+				if m.(string) == "err" {
+					return nil, fmt.Errorf("sub error")
+				}
+				return m, nil
+			}),
+		)
+
+	handled := 0
+	embedded := sub.(l.Embedder).EmbedWith(l.EmbedOpts{
+		Name: "sub",
+		HandleErr: func(err error) error {
+			handled++
+			return nil // swallow the error locally
+		},
+		Result: true,
+	})
+
+	// run it twice to make sure the definition isn't changed by running it
+	for i := 0; i < 2; i++ {
+		var msgs []interface{}
+		parentErrs := 0
+
+		errs := make(chan error)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range errs {
+				parentErrs++
+			}
+		}()
+
+		l.New().
+			SetP(func(out chan<- interface{}, errs chan<- error) {
+				out <- "foo"
+				out <- "err"
+				out <- "bar"
+			}).
+			Add(embedded).
+			SetC(func(in <-chan interface{}, errs chan<- error) {
+				for m := range in {
+					msgs = append(msgs, m)
+				}
+			}).
+			SetErrs(errs).
+			Run()
+		close(errs)
+		wg.Wait()
+
+		if parentErrs != 0 {
+			t.Errorf("parent errors: want 0 got %d", parentErrs)
+		}
+		if len(msgs) != 3 {
+			t.Fatalf("message count: want 3 got %d", len(msgs))
+		}
+		res, ok := msgs[2].(*l.EmbedResult)
+		if !ok {
+			t.Fatalf("want *EmbedResult got %T", msgs[2])
+		}
+		if res.Name != "sub" || res.In != 3 || res.Out != 2 || res.Errs != 1 {
+			t.Errorf("unexpected result %s", res)
+		}
+		if !res.Failed() || res.Err.Error() != "sub error" {
+			t.Errorf("want failed result with 'sub error' got %v", res.Err)
+		}
+	}
+
+	if handled != 2 {
+		t.Errorf("handled errors: want 2 got %d", handled)
+	}
+}
@@ -0,0 +1,60 @@
+package line
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+var envelopeType = reflect.TypeOf(&message.Envelope{})
+
+// openEnvelope gets the payload of an envelope for a stage func. The envelope
+// is added to the context so the func can still get it with message.EnvelopeFrom.
+// If the message isn't an envelope it is returned as is with a nil envelope.
+func openEnvelope(ctx context.Context, msg interface{}) (context.Context, interface{}, *message.Envelope) {
+	env, ok := msg.(*message.Envelope)
+	if !ok || env == nil {
+		return ctx, msg, nil
+	}
+	return message.WithEnvelope(ctx, env), env.Payload, env
+}
+
+// sealEnvelope wraps what a stage func returned for the payload of the envelope.
+// The same payload is sent on in the same envelope and a new one in a child
+// of it. Envelopes and nil are returned as is.
+func sealEnvelope(env *message.Envelope, payload, res interface{}) interface{} {
+	if env == nil || res == nil {
+		return res
+	}
+	if _, ok := res.(*message.Envelope); ok {
+		return res
+	}
+	if samePayload(payload, res) {
+		return env
+	}
+	return env.Child(res)
+}
+
+// samePayload is true if b is a. References are the same if they point to
+// the same thing so a payload changed in place is still the same.
+func samePayload(a, b interface{}) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || va.Type() != vb.Type() {
+		return false
+	}
+	switch va.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return va.Pointer() == vb.Pointer()
+	case reflect.Slice:
+		return va.Pointer() == vb.Pointer() && va.Len() == vb.Len()
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// wantsEnvelope is true if a Map func takes the envelope itself
+// instead of the payload.
+func wantsEnvelope(fnv reflect.Value, ctxIdx int) bool {
+	in := fnv.Type().In(ctxIdx + 1)
+	return in.Kind() != reflect.Interface && envelopeType.AssignableTo(in)
+}
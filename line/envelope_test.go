@@ -0,0 +1,72 @@
+package line_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/MasteryConnect/pipe/line"
+	"github.com/MasteryConnect/pipe/message"
+)
+
+func ExampleMap_envelope() {
+	line.New().SetP(func(out chan<- interface{}, errs chan<- error) {
+		out <- message.NewEnvelope("foo").SetHeader("tenant", "acme")
+	}).AddContext(
+		line.Map(strings.ToUpper),
+		line.InlineContext(func(ctx context.Context, m interface{}) (interface{}, error) {
+			env, _ := message.EnvelopeFrom(ctx)
+			return fmt.Sprintf("%s for %s", m, env.Headers["tenant"]), nil
+		}),
+	).Add(
+		line.Stdout,
+	).Run()
+
+	// Output: FOO for acme
+}
+
+func TestEnvelope_lineage(t *testing.T) {
+	src := message.NewEnvelope("foo")
+	var got []*message.Envelope
+
+	line.New().SetP(func(out chan<- interface{}, errs chan<- error) {
+		out <- src
+	}).Add(
+		// the same payload keeps the envelope
+		line.I(func(m interface{}) (interface{}, error) { return m, nil }),
+	).AddContext(
+		// a new payload gets a child envelope
+		line.Map(func(s string) (string, error) { return s + "!", nil }),
+		// a func can take the envelope itself
+		line.Map(func(e *message.Envelope) *message.Envelope {
+			return e.SetHeader("seen", "yes")
+		}),
+		line.ForEach(func(m interface{}) error { return nil }),
+	).SetC(func(in <-chan interface{}, errs chan<- error) {
+		for m := range in {
+			got = append(got, m.(*message.Envelope))
+		}
+	}).Run()
+
+	if len(got) != 1 {
+		t.Fatalf("expected 1 message got %d", len(got))
+	}
+	env := got[0]
+	if env == src || env.Payload != "foo!" {
+		t.Errorf("expected a child envelope with the new payload got %+v", env)
+	}
+	if env.ParentID != src.ID || env.SourceID != src.ID || !env.Ingested.Equal(src.Ingested) {
+		t.Errorf("expected the lineage of %+v got %+v", src, env)
+	}
+	if env.Headers["seen"] != "yes" {
+		t.Errorf("expected the header to be set got %v", env.Headers)
+	}
+	if message.String(env) != "foo!" {
+		t.Errorf("expected the string of the payload got %s", message.String(env))
+	}
+	var s string
+	if !message.Get(env, &s) || s != "foo!" {
+		t.Errorf("expected Get to unwrap the envelope got %q", s)
+	}
+}
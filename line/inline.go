@@ -18,19 +18,21 @@ type InlineTfuncContext func(context.Context, interface{}) (interface{}, error)
 // the in channel and do stuff inside of the range and then send any
 // errors off to the error channel. This func does that
 // for you so you can just write a simpler transformer func.
-// The parameter is the incoming message.
-// The resulting interface{} is the outgoing message to be
+// The parameter is the incoming message or the payload of a
+// *message.Envelope which is carried on to the result. The
+// resulting interface{} is the outgoing message to be
 // sent downstream. If nil is passed, no message will be sent
 // downstream. If and error is returned, it will be sent
 // down the errror channel.
 func Inline(it InlineTfunc) Tfunc {
 	return func(in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
 		for msg := range in {
-			newMsg, err := it(msg)
+			_, payload, env := openEnvelope(context.Background(), msg)
+			newMsg, err := it(payload)
 			if err != nil {
 				errs <- err
 			}
-			if newMsg != nil {
+			if newMsg = sealEnvelope(env, payload, newMsg); newMsg != nil {
 				out <- newMsg
 			}
 		}
@@ -42,7 +44,17 @@ func I(it InlineTfunc) Tfunc {
 	return Inline(it)
 }
 
+// inlineResult holds what an InlineTfuncContext returned.
+type inlineResult struct {
+	msg interface{}
+	err error
+}
+
 // InlineContext wraps an InlineTfunc and returns a Tfunc.
+// Each message is processed with a context that has the deadline
+// of the stage timeout (see SetTimeout and WithTimeout) or of the
+// message's own context. A message that runs out of time is
+// sent as a timeout *StageError and its result is discarded.
 func InlineContext(it InlineTfuncContext) TfuncContext {
 	return func(ctx context.Context, in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
 		for msg := range in {
@@ -52,11 +64,24 @@ func InlineContext(it InlineTfuncContext) TfuncContext {
 				return // stop if context is done
 
 			default:
-				newMsg, err := it(ctx, msg)
+				ectx, payload, env := openEnvelope(ctx, msg)
+				res, err := process(ectx, msg, func(ctx context.Context, _ interface{}) interface{} {
+					newMsg, err := it(ctx, payload)
+					return inlineResult{newMsg, err}
+				})
 				if err != nil {
-					errs <- err
+					if ctx.Err() != nil {
+						return // stop if context is done
+					}
+					errs <- err // the message timed out
+					continue
+				}
+
+				r := res.(inlineResult)
+				if r.err != nil {
+					errs <- r.err
 				}
-				if newMsg != nil {
+				if newMsg := sealEnvelope(env, payload, r.msg); newMsg != nil {
 					out <- newMsg
 				}
 
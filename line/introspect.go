@@ -0,0 +1,32 @@
+package line
+
+// Introspector reports on the runs of a pipeline while they run.
+// Set one on a pipeline with SetIntrospection. The line/introspect
+// package has one that serves the state of the run over HTTP.
+type Introspector interface {
+	// Introspect is called as a run starts. The errors of the run are sent
+	// through the returned channel which passes them on to errs. stop is
+	// called once the run is done and nothing more is sent on the channel.
+	Introspect(run *RunState, errs chan<- error) (runErrs chan<- error, stop func(), err error)
+}
+
+// RunState is a view of a single run of a pipeline for an Introspector.
+type RunState struct {
+	l *Line
+	r *run
+}
+
+// Stats gets the message counts of each stage.
+func (s *RunState) Stats() []StageStats {
+	return s.r.stats()
+}
+
+// Paused returns true if the producer is paused by a Controller.
+func (s *RunState) Paused() bool {
+	return s.r.gate.paused()
+}
+
+// Describe gets the topology of the pipeline with the counts of each stage.
+func (s *RunState) Describe() Node {
+	return s.l.describeRun(s.r)
+}
@@ -0,0 +1,224 @@
+// Package introspect serves the state of a running pipeline over HTTP.
+// It is kept out of the line package so only programs that use it
+// import net/http and net/http/pprof.
+package introspect
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MasteryConnect/pipe/line"
+)
+
+// DefaultAddr is the address the server listens on if none is set.
+const DefaultAddr = "127.0.0.1:6060"
+
+// Server serves the state of a running pipeline over HTTP.
+// Set it on a pipeline with SetIntrospection. The server is started
+// when the pipeline runs and stopped when it is done.
+//
+// The endpoints are:
+//
+//	/pipeline      the topology of the pipeline with the counts of each stage (JSON)
+//	/stages        the in, out and in flight counts of each stage (JSON)
+//	/errors        the most recent errors (JSON)
+//	/metrics       the stage counts in the Prometheus text format
+//	/debug/pprof/  the net/http/pprof profiles
+type Server struct {
+	Addr   string // the local address to listen on (defaults to DefaultAddr)
+	Errors int    // the number of recent errors to keep (defaults to 100)
+
+	// OnListen is called with the address once the server is listening.
+	// This is useful when listening on port 0.
+	OnListen func(net.Addr)
+}
+
+// RecentError is an error the pipeline raised.
+type RecentError struct {
+	Time  time.Time
+	Error string
+}
+
+// stageInfo is the JSON for a stage of the /stages endpoint.
+type stageInfo struct {
+	line.StageStats
+	InFlight int64
+}
+
+// introspector is the server for a single run.
+type introspector struct {
+	run *line.RunState
+
+	srv *http.Server
+	wg  sync.WaitGroup
+
+	errs     chan error // the errors of the run are sent through here to be kept
+	mx       sync.Mutex
+	recent   []RecentError // ring buffer of the most recent errors
+	next     int
+	errCount int64
+}
+
+// Introspect implements the line.Introspector interface. It listens on the
+// address and starts serving. Errors are passed through the returned channel
+// on to errs so the recent ones can be kept.
+func (s *Server) Introspect(run *line.RunState, errs chan<- error) (chan<- error, func(), error) {
+	size := s.Errors
+	if size <= 0 {
+		size = 100
+	}
+	in := &introspector{run: run, recent: make([]RecentError, 0, size)}
+
+	addr := s.Addr
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s.OnListen != nil {
+		s.OnListen(ln.Addr())
+	}
+
+	in.srv = &http.Server{Handler: in.handler()}
+	in.wg.Add(1)
+	go func() {
+		defer in.wg.Done()
+		in.srv.Serve(ln)
+	}()
+
+	in.errs = make(chan error)
+	in.wg.Add(1)
+	go func() {
+		defer in.wg.Done()
+		for err := range in.errs {
+			in.keep(err)
+			errs <- err
+		}
+	}()
+	return in.errs, in.stop, nil
+}
+
+// stop shuts down the server once everything has been sent on the errs channel.
+func (in *introspector) stop() {
+	close(in.errs)
+	in.srv.Close()
+	in.wg.Wait()
+}
+
+// keep adds the error to the ring buffer of recent errors.
+func (in *introspector) keep(err error) {
+	in.mx.Lock()
+	defer in.mx.Unlock()
+
+	e := RecentError{Time: time.Now(), Error: err.Error()}
+	if len(in.recent) < cap(in.recent) {
+		in.recent = append(in.recent, e)
+	} else {
+		in.recent[in.next] = e
+	}
+	in.next = (in.next + 1) % cap(in.recent)
+	in.errCount++
+}
+
+// recentErrors gets the kept errors from oldest to newest and the total count of errors.
+func (in *introspector) recentErrors() ([]RecentError, int64) {
+	in.mx.Lock()
+	defer in.mx.Unlock()
+
+	recent := make([]RecentError, 0, len(in.recent))
+	if len(in.recent) == cap(in.recent) {
+		recent = append(recent, in.recent[in.next:]...)
+		recent = append(recent, in.recent[:in.next]...)
+	} else {
+		recent = append(recent, in.recent...)
+	}
+	return recent, in.errCount
+}
+
+func (in *introspector) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pipeline", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, in.run.Describe())
+	})
+	mux.HandleFunc("/stages", func(w http.ResponseWriter, req *http.Request) {
+		var stages []stageInfo
+		stats := in.run.Stats()
+		for i, st := range stats {
+			stages = append(stages, stageInfo{StageStats: st, InFlight: inFlight(stats, i)})
+		}
+		writeJSON(w, stages)
+	})
+	mux.HandleFunc("/errors", func(w http.ResponseWriter, req *http.Request) {
+		recent, _ := in.recentErrors()
+		writeJSON(w, recent)
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		in.writeMetrics(w)
+	})
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// writeMetrics writes the stage counts in the Prometheus text format.
+func (in *introspector) writeMetrics(w http.ResponseWriter) {
+	stages := in.run.Stats()
+	metric := func(name, kind, help string, val func(i int) int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for i, st := range stages {
+			fmt.Fprintf(w, "%s{stage=\"%s\"} %d\n", name, promEscape(st.Name), val(i))
+		}
+	}
+	metric("line_stage_messages_in_total", "counter", "Messages received by the stage from upstream.",
+		func(i int) int64 { return stages[i].In })
+	metric("line_stage_messages_out_total", "counter", "Messages sent by the stage downstream.",
+		func(i int) int64 { return stages[i].Out })
+	metric("line_stage_in_flight", "gauge", "Messages received by the stage and not sent on.",
+		func(i int) int64 { return inFlight(stages, i) })
+
+	_, count := in.recentErrors()
+	fmt.Fprintf(w, "# HELP line_errors_total Errors raised by the pipeline.\n# TYPE line_errors_total counter\nline_errors_total %d\n", count)
+
+	paused := 0
+	if in.run.Paused() {
+		paused = 1
+	}
+	fmt.Fprintf(w, "# HELP line_paused Whether the producer is paused.\n# TYPE line_paused gauge\nline_paused %d\n", paused)
+}
+
+// inFlight is the number of messages the transformer at i has received and not sent on.
+// Transformers that filter or batch messages keep what they didn't send in this count.
+// The producer and consumer have nothing in flight.
+func inFlight(stages []line.StageStats, i int) int64 {
+	st := stages[i]
+	if i == 0 || i == len(stages)-1 || st.In < st.Out {
+		return 0
+	}
+	return st.In - st.Out
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// promEscape escapes a Prometheus label value.
+func promEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,118 @@
+package introspect_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	l "github.com/MasteryConnect/pipe/line"
+	"github.com/MasteryConnect/pipe/line/introspect"
+)
+
+func TestServer(t *testing.T) {
+	listening := make(chan net.Addr, 1)
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		l.New().SetP(func(out chan<- interface{}, errs chan<- error) {
+			for i := 0; i < 3; i++ {
+				out <- i
+			}
+			errs <- errors.New("boom 1")
+			errs <- errors.New("boom 2")
+			errs <- errors.New("boom 3")
+			<-release // hold the pipeline open while it is inspected
+		}).Add(
+			l.Inline(func(m interface{}) (interface{}, error) {
+				if m.(int) == 0 {
+					return nil, nil // hold on to one
+				}
+				return m, nil
+			}),
+		).SetErrs(make(chan error, 10)).(l.Instrumenter).SetIntrospection(&introspect.Server{
+			Addr:     "127.0.0.1:0",
+			Errors:   2,
+			OnListen: func(addr net.Addr) { listening <- addr },
+		}).Run()
+	}()
+
+	base := "http://" + (<-listening).String()
+	get := func(path string) string {
+		t.Helper()
+		for {
+			res, err := http.Get(base + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("%s: %s", path, res.Status)
+			}
+			// wait for the messages and errors to get through
+			if path != "/metrics" || strings.Contains(string(body), "line_errors_total 3") {
+				return string(body)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	metrics := get("/metrics")
+	for _, want := range []string{
+		"# TYPE line_stage_messages_in_total counter",
+		`line_stage_messages_out_total{stage="producer"} 3`,
+		`line_stage_messages_in_total{stage="consumer"} 2`,
+		`line_stage_in_flight{stage="consumer"} 0`,
+		"line_paused 0",
+		"} 1\n", // the Inline stage is holding on to one
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("want %q in the metrics got:\n%s", want, metrics)
+		}
+	}
+
+	var stages []struct {
+		Name              string
+		In, Out, InFlight int64
+	}
+	if err := json.Unmarshal([]byte(get("/stages")), &stages); err != nil {
+		t.Fatal(err)
+	}
+	if len(stages) != 3 || stages[1].In != 3 || stages[1].Out != 2 || stages[1].InFlight != 1 {
+		t.Errorf("want the stage counts got %+v", stages)
+	}
+
+	var recent []introspect.RecentError
+	if err := json.Unmarshal([]byte(get("/errors")), &recent); err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 2 || recent[0].Error != "boom 2" || recent[1].Error != "boom 3" {
+		t.Errorf("want the 2 most recent errors got %+v", recent)
+	}
+
+	var n l.Node
+	if err := json.Unmarshal([]byte(get("/pipeline")), &n); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.Children) != 3 || n.Children[0].Stats == nil || n.Children[0].Stats.Out != 3 {
+		t.Errorf("want the topology with counts got %+v", n)
+	}
+
+	if !strings.Contains(get("/debug/pprof/"), "goroutine") {
+		t.Error("want the pprof index")
+	}
+
+	close(release)
+	<-done
+
+	if _, err := http.Get(base + "/stages"); err == nil {
+		t.Error("want the server stopped with the pipeline")
+	}
+}
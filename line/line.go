@@ -6,13 +6,39 @@
 //
 package line
 
-import "sync"
+import (
+	"sync"
+	"time"
+
+	"github.com/MasteryConnect/pipe/trace"
+)
 
 // tfuncEnum holds either a Tfunc or a TfuncContext
 // and can be in a slice as either one
 type tfuncEnum struct {
 	Tfunc
 	TfuncContext
+
+	src interface{} // the Transformer the Tfunc came from if added with AddTransformer
+}
+
+// name gets a readable name for the transformer.
+func (t tfuncEnum) name() string {
+	if t.src != nil {
+		return typeName(t.src)
+	}
+	if t.TfuncContext != nil {
+		return funcName(t.TfuncContext)
+	}
+	return funcName(t.Tfunc)
+}
+
+// describe describes the transformer.
+func (t tfuncEnum) describe() Node {
+	if t.src != nil {
+		return Describe(t.src)
+	}
+	return Node{Name: t.name(), Kind: KindTransformer, Concurrency: 1}
 }
 
 // Line is the order of the steps in the pipe to make a pipeline.
@@ -24,6 +50,11 @@ type Line struct {
 
 	errs   chan<- error
 	errswg *sync.WaitGroup
+
+	watchdog      *Watchdog
+	exporter      trace.Exporter
+	introspection Introspector
+	timeout       time.Duration
 }
 
 // SetP will add the producer to the pipeline.
@@ -53,6 +84,16 @@ func (l *Line) Add(f ...Tfunc) Pipeline {
 	return l // allow chaining
 }
 
+// AddTransformer will add transformers to the pipeline.
+// This is like Add with the T func of each transformer, but the
+// pipeline keeps the transformer around so it can be described.
+func (l *Line) AddTransformer(ts ...Transformer) Pipeline {
+	for _, t := range ts {
+		l.t = append(l.t, tfuncEnum{Tfunc: t.T, src: t})
+	}
+	return l // allow chaining
+}
+
 // AddContext is like Add but with a context.Context
 func (l *Line) AddContext(f ...TfuncContext) Pipeline {
 	if f != nil {
@@ -80,6 +121,46 @@ func (l *Line) SetErrs(errs chan<- error) Pipeline {
 	return l // allow chaining
 }
 
+// SetWatchdog will watch the pipeline for stalls while it runs.
+// See Watchdog for the options.
+func (l *Line) SetWatchdog(w *Watchdog) Pipeline {
+	l.watchdog = w
+	return l // allow chaining
+}
+
+// SetTracer will record a span for each stage traced messages pass
+// through and send them to the exporter. See the trace package.
+func (l *Line) SetTracer(e trace.Exporter) Pipeline {
+	l.exporter = e
+	return l // allow chaining
+}
+
+// SetIntrospection will report on the state of the pipeline while it runs.
+// See the line/introspect package to serve it over HTTP.
+func (l *Line) SetIntrospection(i Introspector) Pipeline {
+	l.introspection = i
+	return l // allow chaining
+}
+
+// SetTimeout sets the max time each stage can spend on a single message.
+// Only stages that process one message at a time like InlineContext, Map,
+// ForEach, Filter and OrderedMany use it. Use WithTimeout to set the
+// timeout of a single stage.
+func (l *Line) SetTimeout(d time.Duration) Pipeline {
+	l.timeout = d
+	return l // allow chaining
+}
+
+// instrumented returns true if the stages need to be connected with links.
+func (l *Line) instrumented() bool {
+	return l.watching() || l.exporter != nil || l.introspection != nil
+}
+
+// watching returns true if the watchdog is set up to watch the pipeline.
+func (l *Line) watching() bool {
+	return l.watchdog != nil && l.watchdog.Timeout > 0
+}
+
 // Filter is syntactic sugar around the Filter transformer
 func (l *Line) Filter(fn interface{}) Pipeline {
 	return l.AddContext(ForEach(fn))
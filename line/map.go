@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+
+	"github.com/MasteryConnect/pipe/message"
 )
 
 // ErrMapArgWrongShape is the error returned when the func shape isn't correct.
@@ -17,6 +19,9 @@ var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
 // If a nil value is returned, no message will be pass along.
 // The passed fund needs to be of the shape
 //		func(<in>) (<out>, error)
+// Each message is given a deadline like with InlineContext.
+// The func is given the payload of a *message.Envelope unless
+// it takes the envelope itself and the result is sent on in the envelope.
 func Map(fn interface{}) TfuncContext {
 	ctxIdx, outIdx, errIdx, err := validateMapArgType(fn)
 	if err != nil {
@@ -24,6 +29,7 @@ func Map(fn interface{}) TfuncContext {
 	}
 
 	fnv := reflect.ValueOf(fn)
+	raw := wantsEnvelope(fnv, ctxIdx)
 
 	return func(ctx context.Context, in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
 		for msg := range in {
@@ -36,12 +42,26 @@ func Map(fn interface{}) TfuncContext {
 			default: // let it fall through if ctx isn't done
 			}
 
-			var res []reflect.Value
-			if ctxIdx == 0 {
-				res = fnv.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(msg)})
-			} else {
-				res = fnv.Call([]reflect.Value{reflect.ValueOf(msg)})
+			// call the func with the context for the message
+			// and the payload if the message is an envelope
+			ectx, payload, env := openEnvelope(ctx, msg)
+			if raw {
+				payload, env = msg, nil
 			}
+			v, err := process(ectx, msg, func(ctx context.Context, _ interface{}) interface{} {
+				if ctxIdx == 0 {
+					return fnv.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(payload)})
+				}
+				return fnv.Call([]reflect.Value{reflect.ValueOf(payload)})
+			})
+			if err != nil {
+				errs <- err
+				if ctx.Err() != nil {
+					return
+				}
+				continue // the message timed out
+			}
+			res := v.([]reflect.Value)
 
 			// examine the error response
 			if errIdx >= 0 {
@@ -54,7 +74,7 @@ func Map(fn interface{}) TfuncContext {
 			// send the new message on instead of the original message
 			// and filter out if nil
 			if outIdx >= 0 {
-				newMsg := res[outIdx].Interface()
+				newMsg := sealEnvelope(env, payload, res[outIdx].Interface())
 				if newMsg != nil {
 					out <- newMsg
 				}
@@ -66,6 +86,57 @@ func Map(fn interface{}) TfuncContext {
 	}
 }
 
+// MapBatch is like Map but it is batch aware. The func is applied to each
+// message inside of a message.Batch (or message.Batcher like an x.GroupMsg)
+// and the results are sent on in a batch of their own so the batch
+// boundaries are kept. Batches left empty aren't sent on. Any other
+// messages are mapped like with Map.
+func MapBatch(fn interface{}) TfuncContext {
+	process := mapPerMessage(fn)
+
+	mapBatch := func(ctx context.Context, b message.Batch, errs chan<- error) message.Batch {
+		newBatch := message.Batch{}
+		for _, msg := range b {
+			msgs, errList := process(ctx, msg)
+			for _, err := range errList {
+				errs <- err
+			}
+			newBatch = append(newBatch, msgs...)
+		}
+		return newBatch
+	}
+
+	return func(ctx context.Context, in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
+		for msg := range in {
+			select {
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			default:
+			}
+
+			switch v := msg.(type) {
+			case message.Batch:
+				if b := mapBatch(ctx, v, errs); len(b) > 0 {
+					out <- b
+				}
+			case message.Batcher:
+				if b := mapBatch(ctx, v.GetBatch(), errs); len(b) > 0 {
+					out <- v.WithBatch(b)
+				}
+			default:
+				msgs, errList := process(ctx, msg)
+				for _, err := range errList {
+					errs <- err
+				}
+				for _, m := range msgs {
+					out <- m
+				}
+			}
+		}
+	}
+}
+
 // ForEach is a wrapper to Map for code readability
 func ForEach(fn interface{}) TfuncContext {
 	return Map(fn)
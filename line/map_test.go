@@ -3,9 +3,11 @@ package line_test
 import (
 	"context"
 	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/MasteryConnect/pipe/line"
+	"github.com/MasteryConnect/pipe/message"
 )
 
 type foo struct {
@@ -176,3 +178,33 @@ func TestMap(t *testing.T) {
 		})
 	})
 }
+
+func TestMapBatch(t *testing.T) {
+	in := make(chan interface{}, 3)
+	in <- message.Batch{1, 2, 3}
+	in <- message.Batch{2}
+	in <- 5
+	close(in)
+
+	out := make(chan interface{}, 10)
+	errs := make(chan error, 10)
+	line.MapBatch(func(m int) (interface{}, error) {
+		if m == 2 {
+			return nil, errors.New("no twos")
+		}
+		return m * 10, nil
+	})(context.Background(), in, out, errs)
+	close(out)
+	close(errs)
+
+	var got []interface{}
+	for m := range out {
+		got = append(got, m)
+	}
+	if !reflect.DeepEqual(got, []interface{}{message.Batch{10, 30}, 50}) {
+		t.Errorf("want [10 30] 50 got %v", got)
+	}
+	if len(errs) != 2 {
+		t.Errorf("want 2 errors got %d", len(errs))
+	}
+}
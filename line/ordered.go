@@ -0,0 +1,237 @@
+package line
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// orderedFunc processes a single message and returns all the resulting messages and errors.
+type orderedFunc func(context.Context, interface{}) ([]interface{}, []error)
+
+// OrderedMany runs fn in multiple go routines like Many, but sends the
+// results downstream in the same order the messages came in.
+//
+// The fn can be a Tfunc, TfuncContext, InlineTfunc, InlineTfuncContext or any
+// func Map accepts. A Tfunc is run once per message so everything it sends
+// for a message stays together in that message's slot. A nil result or an
+// error still holds its slot, so errors are sent in order with the messages.
+//
+// The optional window is the max number of messages being processed or
+// waiting to be sent on at once. This bounds the reorder buffer when a slow
+// message holds up the ones after it. It defaults to twice the concurrency.
+// While window is a slice, only the [0] value is used.
+//
+// InlineTfuncContext, InlineTfunc and Map funcs are given a deadline for
+// each message like with InlineContext.
+func OrderedMany(fn interface{}, concurrency int, window ...int) TfuncContext {
+	process := toOrderedFunc(fn)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	size := concurrency * 2
+	if len(window) > 0 && window[0] > 0 {
+		size = window[0]
+	}
+	if size < concurrency {
+		size = concurrency // no point having more workers than slots
+	}
+
+	type job struct {
+		seq int
+		msg interface{}
+	}
+	type result struct {
+		seq  int
+		msgs []interface{}
+		errs []error
+	}
+
+	return func(ctx context.Context, in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
+		slots := make(chan struct{}, size)
+		jobs := make(chan job)
+		results := make(chan result)
+
+		// hand out the messages in order
+		go func() {
+			defer close(jobs)
+			seq := 0
+			for msg := range in {
+				select {
+				case <-ctx.Done():
+					errs <- ctx.Err()
+					go drain(in) // let upstream finish
+					return
+				case slots <- struct{}{}: // wait for room in the window
+				}
+				jobs <- job{seq: seq, msg: msg}
+				seq++
+			}
+		}()
+
+		// process the messages concurrently
+		var wg sync.WaitGroup
+		wg.Add(concurrency)
+		for n := 0; n < concurrency; n++ {
+			go func() {
+				defer wg.Done()
+				for j := range jobs {
+					msgs, errList := process(ctx, j.msg)
+					results <- result{seq: j.seq, msgs: msgs, errs: errList}
+				}
+			}()
+		}
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+
+		// put the results back in order before sending them on
+		pending := map[int]result{}
+		next := 0
+		for res := range results {
+			pending[res.seq] = res
+			for {
+				r, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+
+				for _, err := range r.errs {
+					errs <- err
+				}
+				for _, msg := range r.msgs {
+					out <- msg
+				}
+				<-slots // free up the slot in the window
+			}
+		}
+	}
+}
+
+// toOrderedFunc converts the supported func shapes to an orderedFunc.
+func toOrderedFunc(fn interface{}) orderedFunc {
+	switch f := fn.(type) {
+	case Tfunc:
+		return tfuncPerMessage(func(ctx context.Context, in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
+			f(in, out, errs)
+		})
+	case func(<-chan interface{}, chan<- interface{}, chan<- error):
+		return toOrderedFunc(Tfunc(f))
+	case TfuncContext:
+		return tfuncPerMessage(f)
+	case func(context.Context, <-chan interface{}, chan<- interface{}, chan<- error):
+		return tfuncPerMessage(f)
+	case InlineTfunc:
+		return inlinePerMessage(func(ctx context.Context, msg interface{}) (interface{}, error) {
+			return f(msg)
+		})
+	case InlineTfuncContext:
+		return inlinePerMessage(f)
+	}
+	return mapPerMessage(fn)
+}
+
+func tfuncPerMessage(t TfuncContext) orderedFunc {
+	return func(ctx context.Context, msg interface{}) ([]interface{}, []error) {
+		in := make(chan interface{}, 1)
+		out := make(chan interface{})
+		errs := make(chan error)
+		in <- msg
+		close(in)
+
+		go func() {
+			defer close(out)
+			defer close(errs)
+			t(ctx, in, out, errs)
+		}()
+
+		var msgs []interface{}
+		var errList []error
+		for out != nil || errs != nil {
+			select {
+			case m, ok := <-out:
+				if !ok {
+					out = nil
+					continue
+				}
+				msgs = append(msgs, m)
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				errList = append(errList, err)
+			}
+		}
+		return msgs, errList
+	}
+}
+
+func inlinePerMessage(it InlineTfuncContext) orderedFunc {
+	return func(ctx context.Context, msg interface{}) ([]interface{}, []error) {
+		var msgs []interface{}
+		var errList []error
+		ectx, payload, env := openEnvelope(ctx, msg)
+		res, err := process(ectx, msg, func(ctx context.Context, _ interface{}) interface{} {
+			newMsg, err := it(ctx, payload)
+			return inlineResult{newMsg, err}
+		})
+		if err != nil {
+			return nil, []error{err}
+		}
+		r := res.(inlineResult)
+		if r.err != nil {
+			errList = append(errList, r.err)
+		}
+		if newMsg := sealEnvelope(env, payload, r.msg); newMsg != nil {
+			msgs = append(msgs, newMsg)
+		}
+		return msgs, errList
+	}
+}
+
+// mapPerMessage uses the same func shapes as Map and will panic if the shape is wrong.
+func mapPerMessage(fn interface{}) orderedFunc {
+	ctxIdx, outIdx, errIdx, err := validateMapArgType(fn)
+	if err != nil {
+		panic(err)
+	}
+	fnv := reflect.ValueOf(fn)
+	raw := wantsEnvelope(fnv, ctxIdx)
+
+	return func(ctx context.Context, msg interface{}) ([]interface{}, []error) {
+		ectx, payload, env := openEnvelope(ctx, msg)
+		if raw {
+			payload, env = msg, nil
+		}
+		v, err := process(ectx, msg, func(ctx context.Context, _ interface{}) interface{} {
+			if ctxIdx == 0 {
+				return fnv.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(payload)})
+			}
+			return fnv.Call([]reflect.Value{reflect.ValueOf(payload)})
+		})
+		if err != nil {
+			return nil, []error{err}
+		}
+		res := v.([]reflect.Value)
+
+		var msgs []interface{}
+		var errList []error
+		if errIdx >= 0 {
+			if err := res[errIdx].Interface(); err != nil {
+				errList = append(errList, err.(error))
+			}
+		}
+		if outIdx >= 0 {
+			if newMsg := sealEnvelope(env, payload, res[outIdx].Interface()); newMsg != nil {
+				msgs = append(msgs, newMsg)
+			}
+		} else {
+			msgs = append(msgs, msg) // no output in the func signature so pass on the original
+		}
+		return msgs, errList
+	}
+}
@@ -0,0 +1,130 @@
+package line_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	l "github.com/MasteryConnect/pipe/line"
+)
+
+func ExampleOrderedMany() {
+	l.New().
+		SetP(func(out chan<- interface{}, errs chan<- error) {
+			for i := 3; i > 0; i-- {
+				out <- i
+			}
+		}).
+		AddContext(
+			l.OrderedMany(func(i int) string {
+				// the first messages take the longest but still come out first
+				time.Sleep(time.Duration(i) * time.Millisecond)
+				return fmt.Sprintf("done %d", i)
+			}, 3),
+		).
+		Add(l.Stdout).
+		Run()
+
+	// Output:
+	// done 3
+	// done 2
+	// done 1
+}
+
+func TestOrderedMany(t *testing.T) {
+	var got []interface{}
+	var gotErrs []string
+
+	errs := make(chan error)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for err := range errs {
+			gotErrs = append(gotErrs, err.Error())
+		}
+	}()
+
+	l.New().
+		SetP(func(out chan<- interface{}, errs chan<- error) {
+			for i := 0; i < 100; i++ {
+				out <- i
+			}
+		}).
+		AddContext(
+			l.OrderedMany(l.InlineTfunc(func(m interface{}) (interface{}, error) {
+				i := m.(int)
+				time.Sleep(time.Duration(i%7) * 100 * time.Microsecond)
+				switch i % 10 {
+				case 3:
+					return nil, nil // drop it
+				case 5:
+					return nil, fmt.Errorf("%d", i)
+				}
+				return i, nil
+			}), 8, 16),
+		).
+		SetC(func(in <-chan interface{}, errs chan<- error) {
+			for m := range in {
+				got = append(got, m)
+			}
+		}).
+		SetErrs(errs).
+		Run()
+	close(errs)
+	<-done
+
+	var want []interface{}
+	var wantErrs []string
+	for i := 0; i < 100; i++ {
+		switch i % 10 {
+		case 3:
+		case 5:
+			wantErrs = append(wantErrs, fmt.Sprint(i))
+		default:
+			want = append(want, i)
+		}
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v got %v", want, got)
+	}
+	if !reflect.DeepEqual(wantErrs, gotErrs) {
+		t.Errorf("want errs %v got %v", wantErrs, gotErrs)
+	}
+}
+
+func TestOrderedMany_tfunc(t *testing.T) {
+	var got []interface{}
+
+	l.New().
+		SetP(func(out chan<- interface{}, errs chan<- error) {
+			for i := 0; i < 50; i++ {
+				out <- i
+			}
+		}).
+		AddContext(
+			l.OrderedMany(func(in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
+				for m := range in {
+					time.Sleep(time.Duration(m.(int)%3) * 100 * time.Microsecond)
+					out <- m
+					out <- m // each message sends two
+				}
+			}, 4),
+		).
+		SetC(func(in <-chan interface{}, errs chan<- error) {
+			for m := range in {
+				got = append(got, m)
+			}
+		}).
+		Run()
+
+	if len(got) != 100 {
+		t.Fatalf("want 100 got %d", len(got))
+	}
+	for i, m := range got {
+		if m.(int) != i/2 {
+			t.Fatalf("out of order at %d: want %d got %v", i, i/2, m)
+		}
+	}
+}
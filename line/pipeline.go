@@ -1,6 +1,11 @@
 package line
 
-import "context"
+import (
+	"context"
+	"time"
+
+	"github.com/MasteryConnect/pipe/trace"
+)
 
 // Pfunc is the function signature for a producer func.
 type Pfunc func(chan<- interface{}, chan<- error)
@@ -37,6 +42,42 @@ type Pipeline interface {
 	Embed(<-chan interface{}, chan<- interface{}, chan<- error) // act as a Tfunc
 }
 
+// The optional interfaces of a Pipeline. Line implements all of them
+// (and Describer). Type assert a Pipeline for the ones you need so
+// other implementations of Pipeline don't have to.
+type (
+	// TransformerAdder can add Transformers to the pipeline.
+	TransformerAdder interface {
+		AddTransformer(...Transformer) Pipeline
+	}
+
+	// Instrumenter can watch, trace, introspect and time out
+	// the stages of the pipeline while it runs.
+	Instrumenter interface {
+		SetWatchdog(*Watchdog) Pipeline
+		SetTracer(trace.Exporter) Pipeline
+		SetIntrospection(Introspector) Pipeline
+		SetTimeout(time.Duration) Pipeline
+	}
+
+	// Starter can run the pipeline in the background with a Controller.
+	Starter interface {
+		Start() *Controller
+		StartContext(context.Context) *Controller
+	}
+
+	// Embedder can embed the pipeline in another with its own error handling.
+	Embedder interface {
+		EmbedWith(EmbedOpts) Tfunc
+	}
+)
+
+// Transformer is anything with a T method that is a Tfunc.
+// Most of the transformers in the x package are Transformers.
+type Transformer interface {
+	T(<-chan interface{}, chan<- interface{}, chan<- error)
+}
+
 // Acker is something that can be "Ack"ed.
 type Acker interface {
 	Ack()
@@ -5,6 +5,8 @@ import (
 	"log"
 	"os"
 	"sync"
+
+	"github.com/MasteryConnect/pipe/trace"
 )
 
 // Run runs the whole pipeline.
@@ -14,6 +16,15 @@ func (l *Line) Run() error {
 
 // RunContext runs the whole pipeline with context.Context.
 func (l *Line) RunContext(ctx context.Context) error {
+	// setup the cancelable context so a run can be aborted (ex: by the watchdog)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	return l.execute(ctx, l.newRun(cancel, l.instrumented()))
+}
+
+// execute runs the stages of the pipeline and blocks until the consumer is done.
+func (l *Line) execute(ctx context.Context, r *run) error {
 	var errswg *sync.WaitGroup
 	var errs chan<- error
 	if l.errs != nil {
@@ -21,41 +32,100 @@ func (l *Line) RunContext(ctx context.Context) error {
 	} else {
 		errs, errswg = makeErrors()
 	}
+	rootErrs := errs
+
+	// report on the state of the run
+	var stopIntrospection func()
+	if l.introspection != nil {
+		runErrs, stop, err := l.introspection.Introspect(&RunState{l: l, r: r}, errs)
+		if err != nil {
+			errs <- err
+		} else {
+			errs, stopIntrospection = runErrs, stop
+		}
+	}
 
-	// setup the channel for the producer
-	var in chan interface{}
-	var out chan interface{}
+	// hook the tracer into the links
+	var tr *tracer
+	if l.exporter != nil && len(r.links) > 0 {
+		ctx = trace.WithExporter(ctx, l.exporter)
+		tr = newTracer(r, l.exporter, errs)
+		for i, lk := range r.links {
+			lk.hook = tr.hook(i)
+		}
+	}
 
 	// make the out channel for the producer
-	out = make(chan interface{})
+	out := make(chan interface{})
 
-	go l.spinUpProducer(ctx, out, errs)
+	go func(st *stage, out chan interface{}) {
+		st.start()
+		l.spinUpProducer(ctx, out, errs)
+	}(r.stages[0], out)
 
-	for _, t := range l.t {
-		in = out
+	for i, t := range l.t {
+		in := r.connect(i, out)
 		out = make(chan interface{})
 
-		// choose the context version first if exists
-		if t.TfuncContext != nil {
-			go spinUpTransformersContext(ctx, t.TfuncContext, 1, in, out, errs)
-		} else if t.Tfunc != nil {
-			go spinUpTransformers(t.Tfunc, 1, in, out, errs)
-		}
+		go func(st *stage, t tfuncEnum, in, out chan interface{}) {
+			st.start()
+
+			// choose the context version first if exists
+			if t.TfuncContext != nil {
+				spinUpTransformersContext(l.stageContext(ctx, st), t.TfuncContext, 1, in, out, errs)
+			} else if t.Tfunc != nil {
+				spinUpTransformers(t.Tfunc, 1, in, out, errs)
+			}
+		}(r.stages[i+1], t, in, out)
 	}
 
-	l.c(out, errs)
+	in := r.connect(len(l.t), out)
+
+	// start watching for stalls
+	done := make(chan struct{})
+	var watchwg sync.WaitGroup
+	if l.watching() {
+		watchwg.Add(1)
+		go func() {
+			defer watchwg.Done()
+			l.watchdog.watch(r, errs, done)
+		}()
+	}
+
+	r.stages[len(r.stages)-1].start()
+	l.c(in, errs)
+
+	close(done)
+	watchwg.Wait()
+
+	if tr != nil {
+		tr.finish()
+	}
+
+	if stopIntrospection != nil {
+		stopIntrospection()
+	}
 
 	if l.errs == nil {
 		// if we weren't passed the channel
 		// we made it and need to close it
-		safeCloseErrs(errs)
+		safeCloseErrs(rootErrs)
 	}
 
 	if errswg != nil {
 		errswg.Wait()
 	}
 
-	return nil
+	return r.Err()
+}
+
+// stageContext is the context for a transformer with its name and the pipeline timeout.
+func (l *Line) stageContext(ctx context.Context, st *stage) context.Context {
+	ctx = withStage(ctx, st.name)
+	if l.timeout > 0 {
+		ctx = context.WithValue(ctx, stageTimeoutKey, l.timeout)
+	}
+	return ctx
 }
 
 // if p is nil, then the produer is overridden and the GetIn() must be used
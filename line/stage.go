@@ -0,0 +1,280 @@
+package line
+
+import (
+	"bytes"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// link states
+const (
+	linkReceiving int32 = iota // waiting on the upstream stage to send
+	linkSending                // holding a message waiting on the downstream stage
+)
+
+// stage holds the runtime info for a single step of a running pipeline.
+type stage struct {
+	name  string
+	gid   int64 // id of the goroutine running the stage
+	track bool  // record gid for the watchdog or introspection
+}
+
+// start records the goroutine the stage is running in when it's tracked.
+func (s *stage) start() {
+	if s.track {
+		atomic.StoreInt64(&s.gid, goid())
+	}
+}
+
+// link is an instrumented connection between two stages.
+// It relays messages from one stage to the next and keeps track
+// of how many messages have moved and when the last one did.
+type link struct {
+	count int64
+	moved int64 // unix nano of the last movement
+	state int32
+
+	hook func(interface{}) interface{} // optionally called with each message before it is passed on
+}
+
+func newLink() *link {
+	return &link{moved: time.Now().UnixNano()}
+}
+
+// relay passes the messages from one stage on to the next until from is closed
+// or stop is closed. When stopped, from is drained so the upstream stage isn't blocked.
+// If a gate is given, no messages are taken from upstream while it is paused.
+func (lk *link) relay(stop <-chan struct{}, g *gate, from <-chan interface{}, to chan<- interface{}) {
+	defer close(to)
+	for {
+		if g != nil {
+			g.wait(stop)
+		}
+		select {
+		case msg, ok := <-from:
+			if !ok {
+				return
+			}
+			atomic.StoreInt64(&lk.moved, time.Now().UnixNano())
+			atomic.StoreInt32(&lk.state, linkSending)
+			if lk.hook != nil {
+				msg = lk.hook(msg)
+			}
+			select {
+			case to <- msg:
+			case <-stop:
+				go drain(from)
+				return
+			}
+			atomic.AddInt64(&lk.count, 1)
+			atomic.StoreInt64(&lk.moved, time.Now().UnixNano())
+			atomic.StoreInt32(&lk.state, linkReceiving)
+		case <-stop:
+			go drain(from)
+			return
+		}
+	}
+}
+
+func (lk *link) sending() bool {
+	return lk != nil && atomic.LoadInt32(&lk.state) == linkSending
+}
+
+func (lk *link) lastMoved() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&lk.moved))
+}
+
+// touch marks the link as having just moved.
+func (lk *link) touch() {
+	atomic.StoreInt64(&lk.moved, time.Now().UnixNano())
+}
+
+// gate blocks a link while it is paused.
+type gate struct {
+	mx     sync.Mutex
+	resume chan struct{} // nil when not paused
+}
+
+func (g *gate) pause() {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+	if g.resume == nil {
+		g.resume = make(chan struct{})
+	}
+}
+
+func (g *gate) open() {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+	if g.resume != nil {
+		close(g.resume)
+		g.resume = nil
+	}
+}
+
+func (g *gate) paused() bool {
+	if g == nil {
+		return false
+	}
+	g.mx.Lock()
+	defer g.mx.Unlock()
+	return g.resume != nil
+}
+
+// wait blocks while the gate is paused or until stop is closed.
+func (g *gate) wait(stop <-chan struct{}) {
+	g.mx.Lock()
+	resume := g.resume
+	g.mx.Unlock()
+	if resume == nil {
+		return
+	}
+	select {
+	case <-resume:
+	case <-stop:
+	}
+}
+
+// run holds the runtime state for a single run of a Line.
+type run struct {
+	stages []*stage
+	links  []*link // links[i] connects stages[i] to stages[i+1] when instrumented
+
+	gate *gate // pauses the producer when set
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	cancel   func()
+
+	mx  sync.Mutex
+	err error
+}
+
+// newRun sets up the stages for the pipeline. Instrumented runs will
+// connect the stages with links.
+func (l *Line) newRun(cancel func(), instrument bool) *run {
+	r := &run{stop: make(chan struct{}), cancel: cancel}
+	track := l.watching() || l.introspection != nil
+	r.stages = append(r.stages, &stage{name: "producer", track: track})
+	for i, t := range l.t {
+		r.stages = append(r.stages, &stage{name: strconv.Itoa(i+1) + ":" + t.name(), track: track})
+	}
+	r.stages = append(r.stages, &stage{name: "consumer", track: track})
+
+	if instrument {
+		for i := 0; i < len(r.stages)-1; i++ {
+			r.links = append(r.links, newLink())
+		}
+	}
+	return r
+}
+
+// connect returns the channel the next stage should read from.
+// If the run is instrumented the out channel of stage i is relayed
+// through a link, otherwise out is used directly.
+func (r *run) connect(i int, out chan interface{}) chan interface{} {
+	if len(r.links) == 0 {
+		return out
+	}
+	in := make(chan interface{})
+	var g *gate
+	if i == 0 {
+		g = r.gate // only gate the producer
+	}
+	go r.links[i].relay(r.stop, g, out, in)
+	return in
+}
+
+// abort stops the run with the given error.
+// The links stop relaying and the context is cancelled.
+func (r *run) abort(err error) {
+	r.stopOnce.Do(func() {
+		r.mx.Lock()
+		r.err = err
+		r.mx.Unlock()
+		close(r.stop)
+		if r.cancel != nil {
+			r.cancel()
+		}
+	})
+}
+
+// Err returns the error the run was aborted with if any.
+func (r *run) Err() error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.err
+}
+
+func drain(ch <-chan interface{}) {
+	for range ch {
+	}
+}
+
+// funcName gets a readable name for a producer, transformer or consumer func.
+func funcName(fn interface{}) string {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return "<nil>"
+	}
+	f := runtime.FuncForPC(v.Pointer())
+	if f == nil {
+		return "<unknown>"
+	}
+	name := f.Name()
+	// trim the import path down to the package name
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(name, "-fm")
+}
+
+// typeName gets a readable name for the type of a stage.
+func typeName(v interface{}) string {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Func {
+		return funcName(v)
+	}
+	return t.String()
+}
+
+// goid gets the id of the current goroutine.
+func goid() int64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i >= 0 {
+		buf = buf[:i]
+	}
+	id, _ := strconv.ParseInt(string(buf), 10, 64)
+	return id
+}
+
+// goroutineStacks gets the stacks of all goroutines keyed by goroutine id.
+func goroutineStacks() map[int64]string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+
+	stacks := map[int64]string{}
+	for _, block := range strings.Split(string(buf), "\n\n") {
+		header := strings.TrimPrefix(block, "goroutine ")
+		if i := strings.IndexByte(header, ' '); i >= 0 {
+			if id, err := strconv.ParseInt(header[:i], 10, 64); err == nil {
+				stacks[id] = block
+			}
+		}
+	}
+	return stacks
+}
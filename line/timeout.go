@@ -0,0 +1,122 @@
+package line
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+// StageError is the error sent when a stage fails to process a message.
+// A stage that times out on a message sends a StageError with
+// context.DeadlineExceeded as the Err and moves on to the next message.
+type StageError struct {
+	Stage string      // the name of the stage
+	Msg   interface{} // the message the stage failed on
+	Err   error
+}
+
+// Error implements the error interface
+func (e *StageError) Error() string {
+	return fmt.Sprintf("stage %s: %v", e.Stage, e.Err)
+}
+
+// Cause returns the underlying error (see github.com/pkg/errors).
+func (e *StageError) Cause() error {
+	return e.Err
+}
+
+// Timeout returns true if the stage ran out of time processing the message.
+func (e *StageError) Timeout() bool {
+	return e.Err == context.DeadlineExceeded
+}
+
+type ctxKey int
+
+const (
+	stageNameKey ctxKey = iota
+	stageTimeoutKey
+)
+
+// WithTimeout sets the max time the transformer can spend on each message.
+// This overrides the pipeline timeout set with SetTimeout for this stage.
+// Only transformers that process one message at a time like InlineContext,
+// Map, ForEach, Filter and OrderedMany use it. A zero d turns the timeout off.
+func WithTimeout(d time.Duration, t TfuncContext) TfuncContext {
+	return func(ctx context.Context, in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
+		t(context.WithValue(ctx, stageTimeoutKey, d), in, out, errs)
+	}
+}
+
+// withStage names the stage the context is for.
+func withStage(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, stageNameKey, name)
+}
+
+func stageName(ctx context.Context) string {
+	name, _ := ctx.Value(stageNameKey).(string)
+	return name
+}
+
+// messageContext derives the context for processing a single message.
+// The deadline is the stage timeout or the deadline of the message's own
+// context, whichever comes first. ok is false if there is no deadline.
+func messageContext(ctx context.Context, msg interface{}) (mctx context.Context, cancel func(), ok bool) {
+	var deadline time.Time
+	if d, _ := ctx.Value(stageTimeoutKey).(time.Duration); d > 0 {
+		deadline = time.Now().Add(d)
+	}
+	if d, has := messageDeadline(msg); has && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	if deadline.IsZero() {
+		return ctx, func() {}, false
+	}
+	mctx, cancel = context.WithDeadline(ctx, deadline)
+	return mctx, cancel, true
+}
+
+// messageDeadline gets the deadline of the context the message carries if any.
+func messageDeadline(msg interface{}) (time.Time, bool) {
+	var ctx context.Context
+	switch v := msg.(type) {
+	case message.ContextGetter:
+		ctx = v.GetContext()
+	case *http.Request:
+		ctx = v.Context()
+	case message.Inner:
+		return messageDeadline(v.In())
+	}
+	if ctx == nil {
+		return time.Time{}, false
+	}
+	return ctx.Deadline()
+}
+
+// process calls fn with the message and the context for it. If the deadline passes
+// before fn returns, a timeout *StageError is returned and whatever fn returns
+// later is discarded. If the pipeline's context is done, its error is returned.
+func process(ctx context.Context, msg interface{}, fn func(context.Context, interface{}) interface{}) (interface{}, error) {
+	mctx, cancel, ok := messageContext(ctx, msg)
+	defer cancel()
+	if !ok {
+		return fn(ctx, msg), nil
+	}
+
+	done := make(chan interface{}, 1) // buffered so an abandoned fn doesn't block
+	go func() {
+		done <- fn(mctx, msg)
+	}()
+
+	select {
+	case res := <-done:
+		return res, nil
+	case <-mctx.Done():
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &StageError{Stage: stageName(ctx), Msg: msg, Err: mctx.Err()}
+	}
+}
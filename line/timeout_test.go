@@ -0,0 +1,129 @@
+package line_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	l "github.com/MasteryConnect/pipe/line"
+	"github.com/MasteryConnect/pipe/message"
+)
+
+// runTimeouts runs the pipeline and returns the messages and errors that came out.
+func runTimeouts(p l.Pipeline, msgs ...interface{}) ([]interface{}, []error) {
+	var got []interface{}
+	var errList []error
+	errs := make(chan error)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for err := range errs {
+			errList = append(errList, err)
+		}
+	}()
+
+	p.SetP(func(out chan<- interface{}, errs chan<- error) {
+		for _, m := range msgs {
+			out <- m
+		}
+	}).SetC(func(in <-chan interface{}, errs chan<- error) {
+		for m := range in {
+			got = append(got, m)
+		}
+	}).SetErrs(errs).Run()
+
+	close(errs)
+	<-done
+	return got, errList
+}
+
+func TestPipeline_SetTimeout(t *testing.T) {
+	got, errList := runTimeouts(l.New().(l.Instrumenter).SetTimeout(20*time.Millisecond).AddContext(
+		l.InlineContext(func(ctx context.Context, m interface{}) (interface{}, error) {
+			if m == "slow" {
+				<-ctx.Done()
+				return "too late", nil
+			}
+			return m, nil
+		}),
+	), "a", "slow", "b")
+
+	if !reflect.DeepEqual(got, []interface{}{"a", "b"}) {
+		t.Errorf("want a b got %v", got)
+	}
+	if len(errList) != 1 {
+		t.Fatalf("want 1 error got %v", errList)
+	}
+	serr, ok := errList[0].(*l.StageError)
+	if !ok || !serr.Timeout() || serr.Msg != "slow" || serr.Stage == "" {
+		t.Errorf("want a timeout StageError for the slow message got %#v", errList[0])
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	got, errList := runTimeouts(l.New().(l.Instrumenter).SetTimeout(time.Hour).AddContext(
+		l.WithTimeout(20*time.Millisecond, l.Map(func(m string) string {
+			if m == "stuck" {
+				<-release // ignores the context so the result is abandoned
+			}
+			return m
+		})),
+	), "a", "stuck", "b")
+
+	if !reflect.DeepEqual(got, []interface{}{"a", "b"}) {
+		t.Errorf("want a b got %v", got)
+	}
+	if len(errList) != 1 {
+		t.Fatalf("want 1 error got %v", errList)
+	}
+	if serr, ok := errList[0].(*l.StageError); !ok || !serr.Timeout() {
+		t.Errorf("want a timeout StageError got %v", errList[0])
+	}
+}
+
+func TestTimeout_messageDeadline(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	got, errList := runTimeouts(l.New().AddContext(
+		l.InlineContext(func(ctx context.Context, m interface{}) (interface{}, error) {
+			<-ctx.Done()
+			return m, nil
+		}),
+	), message.Query{SQL: "SELECT 1", Context: expired})
+
+	if len(got) != 0 {
+		t.Errorf("want no messages got %v", got)
+	}
+	if len(errList) != 1 {
+		t.Fatalf("want 1 error got %v", errList)
+	}
+	if serr, ok := errList[0].(*l.StageError); !ok || !serr.Timeout() {
+		t.Errorf("want a timeout StageError got %v", errList[0])
+	}
+}
+
+func TestOrderedMany_timeout(t *testing.T) {
+	got, errList := runTimeouts(l.New().(l.Instrumenter).SetTimeout(20*time.Millisecond).AddContext(
+		l.OrderedMany(func(ctx context.Context, m int) (int, error) {
+			if m == 2 {
+				<-ctx.Done()
+			}
+			return m, nil
+		}, 2),
+	), 1, 2, 3)
+
+	if !reflect.DeepEqual(got, []interface{}{1, 3}) {
+		t.Errorf("want 1 3 got %v", got)
+	}
+	if len(errList) != 1 {
+		t.Fatalf("want 1 error got %v", errList)
+	}
+	if serr, ok := errList[0].(*l.StageError); !ok || serr.Msg != 2 {
+		t.Errorf("want a StageError for 2 got %v", errList[0])
+	}
+}
@@ -0,0 +1,87 @@
+package line
+
+import (
+	"sync"
+
+	"github.com/MasteryConnect/pipe/trace"
+)
+
+// tracer records a span for each stage a traced message passes through.
+// A stage's span starts when the message is handed to the stage and ends
+// when a message from the same trace is sent on by the stage.
+type tracer struct {
+	exporter trace.Exporter
+	errs     chan<- error
+
+	mx    sync.Mutex
+	names []string
+	open  []map[string]*trace.ActiveSpan // open spans per stage by trace id
+}
+
+func newTracer(r *run, exporter trace.Exporter, errs chan<- error) *tracer {
+	t := &tracer{exporter: exporter, errs: errs}
+	for _, st := range r.stages {
+		t.names = append(t.names, st.name)
+		t.open = append(t.open, map[string]*trace.ActiveSpan{})
+	}
+	return t
+}
+
+// hook returns the link hook for the link from stage i to stage i+1.
+func (t *tracer) hook(i int) func(interface{}) interface{} {
+	return func(msg interface{}) interface{} {
+		ctx, ok := trace.Context(msg)
+		if !ok {
+			return msg
+		}
+		sc, _ := trace.SpanFrom(ctx)
+
+		t.mx.Lock()
+		// the message is leaving stage i
+		ended, ok := t.open[i][sc.TraceID]
+		delete(t.open[i], sc.TraceID)
+
+		// and entering stage i+1 (the consumer doesn't get a span)
+		if i+1 < len(t.open)-1 {
+			var span *trace.ActiveSpan
+			ctx, span = trace.Start(trace.WithExporter(ctx, t.exporter), t.names[i+1])
+			t.open[i+1][sc.TraceID] = span
+
+			// pass the span on with the message if we can
+			msg = trace.WithContext(msg, ctx)
+		}
+		t.mx.Unlock()
+
+		if ok {
+			t.end(ended, false)
+		}
+		return msg
+	}
+}
+
+// finish ends the spans still open when the run is done.
+// These are from messages dropped by a stage.
+func (t *tracer) finish() {
+	t.mx.Lock()
+	var spans []*trace.ActiveSpan
+	for i := range t.open {
+		for id, span := range t.open[i] {
+			spans = append(spans, span)
+			delete(t.open[i], id)
+		}
+	}
+	t.mx.Unlock()
+
+	for _, span := range spans {
+		t.end(span, true)
+	}
+}
+
+func (t *tracer) end(span *trace.ActiveSpan, unfinished bool) {
+	if unfinished {
+		span.SetAttr("unfinished", true)
+	}
+	if err := span.End(nil); err != nil {
+		t.errs <- err
+	}
+}
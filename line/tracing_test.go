@@ -0,0 +1,61 @@
+package line_test
+
+import (
+	"context"
+	"testing"
+
+	l "github.com/MasteryConnect/pipe/line"
+	"github.com/MasteryConnect/pipe/trace"
+)
+
+func TestPipeline_SetTracer(t *testing.T) {
+	mem := &trace.MemoryExporter{}
+
+	l.New().
+		SetPContext(func(ctx context.Context, out chan<- interface{}, errs chan<- error) {
+			out <- trace.Begin(ctx, "foo")
+			out <- trace.Begin(ctx, "drop")
+			out <- "not traced"
+		}).
+		Add(
+			l.Noop,
+			l.Inline(func(m interface{}) (interface{}, error) {
+				if m, ok := m.(*trace.Message); ok && m.M == "drop" {
+					return nil, nil
+				}
+				return m, nil
+			}),
+		).
+		SetC(l.NoopC).(l.Instrumenter).
+		SetTracer(mem).
+		Run()
+
+	spans := mem.Spans()
+	if len(spans) != 4 {
+		t.Fatalf("want 4 spans got %d: %+v", len(spans), spans)
+	}
+
+	traces := map[string][]trace.Span{}
+	for _, s := range spans {
+		traces[s.TraceID] = append(traces[s.TraceID], s)
+	}
+	if len(traces) != 2 {
+		t.Fatalf("want 2 traces got %d", len(traces))
+	}
+
+	for _, tr := range traces {
+		if len(tr) != 2 {
+			t.Fatalf("want 2 spans per trace got %d", len(tr))
+		}
+		first, second := tr[0], tr[1]
+		if first.Name != "1:line.Noop" || first.ParentID != "" {
+			t.Errorf("unexpected first span %+v", first)
+		}
+		if second.ParentID != first.SpanID {
+			t.Errorf("want the second span to be a child of the first got %+v", second)
+		}
+		if second.Attrs["unfinished"] == true && second.End.IsZero() {
+			t.Errorf("want the dropped span ended got %+v", second)
+		}
+	}
+}
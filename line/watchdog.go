@@ -0,0 +1,163 @@
+package line
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Stage states used in a StallError.
+const (
+	StageReceiving = "receive" // blocked waiting for a message from upstream
+	StageSending   = "send"    // blocked sending a message downstream
+	StageBusy      = "busy"    // holding a message and not sending (working or blocked outside the pipeline)
+)
+
+// Watchdog watches a running pipeline for stalls. The pipeline is
+// considered stalled when no message has moved between any of the stages
+// for the Timeout period. Set it on a pipeline with SetWatchdog.
+type Watchdog struct {
+	Timeout time.Duration // how long no messages can move before it is a stall (required)
+	Stacks  bool          // include the goroutine stacks of the stages in the error
+	Cancel  bool          // cancel the run and return the *StallError from Run
+}
+
+// StageStatus is the status of a single stage of a stalled pipeline.
+type StageStatus struct {
+	Name  string
+	State string        // one of StageReceiving, StageSending or StageBusy
+	Idle  time.Duration // time since a message last moved in or out of the stage
+	Stack string        // the goroutine stack of the stage if Watchdog.Stacks is set
+}
+
+// StallError is the error sent down the errs channel when the watchdog
+// finds a stalled pipeline.
+type StallError struct {
+	Idle   time.Duration
+	Stages []StageStatus
+}
+
+// Error implements the error interface
+func (e *StallError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "pipeline stalled: no messages moved for %v", e.Idle.Round(time.Millisecond))
+	for _, s := range e.Stages {
+		if s.State == StageBusy {
+			fmt.Fprintf(&b, "\n  %s: busy (idle %v)", s.Name, s.Idle.Round(time.Millisecond))
+		} else {
+			fmt.Fprintf(&b, "\n  %s: blocked on %s (idle %v)", s.Name, s.State, s.Idle.Round(time.Millisecond))
+		}
+	}
+	for _, s := range e.Stages {
+		if s.Stack != "" {
+			fmt.Fprintf(&b, "\n\n%s:\n%s", s.Name, s.Stack)
+		}
+	}
+	return b.String()
+}
+
+// Blocked returns the stages that aren't waiting on upstream messages.
+// These are the stages most likely to be the cause of the stall.
+func (e *StallError) Blocked() []StageStatus {
+	var blocked []StageStatus
+	for _, s := range e.Stages {
+		if s.State != StageReceiving {
+			blocked = append(blocked, s)
+		}
+	}
+	return blocked
+}
+
+// watch checks the links of the run until done is closed.
+func (w *Watchdog) watch(r *run, errs chan<- error, done <-chan struct{}) {
+	interval := w.Timeout / 4
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var reported time.Time // the last movement we already reported on
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		if r.gate.paused() {
+			continue // a paused pipeline isn't stalled
+		}
+
+		last := r.lastMoved()
+		if time.Since(last) < w.Timeout || last.Equal(reported) {
+			continue
+		}
+		reported = last
+
+		err := w.report(r)
+		if w.Cancel {
+			r.abort(err)
+		}
+		select {
+		case errs <- err:
+		case <-done:
+			return
+		}
+	}
+}
+
+// report builds the StallError from the current state of the run.
+func (w *Watchdog) report(r *run) *StallError {
+	now := time.Now()
+	err := &StallError{Idle: now.Sub(r.lastMoved())}
+
+	var stacks map[int64]string
+	if w.Stacks {
+		stacks = goroutineStacks()
+	}
+
+	for i, st := range r.stages {
+		var in, out *link
+		if i > 0 {
+			in = r.links[i-1]
+		}
+		if i < len(r.links) {
+			out = r.links[i]
+		}
+
+		status := StageStatus{Name: st.name, State: StageReceiving}
+		switch {
+		case out.sending():
+			status.State = StageSending
+		case in.sending() || in == nil:
+			status.State = StageBusy
+		}
+
+		var last time.Time
+		for _, lk := range []*link{in, out} {
+			if lk != nil && lk.lastMoved().After(last) {
+				last = lk.lastMoved()
+			}
+		}
+		status.Idle = now.Sub(last)
+
+		if stacks != nil {
+			status.Stack = stacks[atomic.LoadInt64(&st.gid)]
+		}
+		err.Stages = append(err.Stages, status)
+	}
+	return err
+}
+
+// lastMoved is the last time a message moved through any of the links.
+func (r *run) lastMoved() time.Time {
+	var last time.Time
+	for _, lk := range r.links {
+		if lk.lastMoved().After(last) {
+			last = lk.lastMoved()
+		}
+	}
+	return last
+}
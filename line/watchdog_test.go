@@ -0,0 +1,90 @@
+package line_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	l "github.com/MasteryConnect/pipe/line"
+)
+
+func TestWatchdog(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	errs := make(chan error, 10)
+	err := l.New().
+		SetP(func(out chan<- interface{}, errs chan<- error) {
+			for i := 0; i < 10; i++ {
+				out <- i
+			}
+		}).
+		Add(
+			l.Noop,
+			func(in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
+				for m := range in {
+					<-block // stop working like a hung child process would
+					out <- m
+				}
+			},
+		).
+		SetErrs(errs).(l.Instrumenter).
+		SetWatchdog(&l.Watchdog{Timeout: 20 * time.Millisecond, Stacks: true, Cancel: true}).
+		Run()
+
+	stall, ok := err.(*l.StallError)
+	if !ok {
+		t.Fatalf("want *StallError got %T %v", err, err)
+	}
+
+	if len(stall.Stages) != 4 {
+		t.Fatalf("want 4 stages got %d", len(stall.Stages))
+	}
+
+	want := []string{l.StageSending, l.StageSending, l.StageBusy, l.StageReceiving}
+	for i, s := range stall.Stages {
+		if s.State != want[i] {
+			t.Errorf("stage %s: want %s got %s", s.Name, want[i], s.State)
+		}
+	}
+
+	blocked := stall.Blocked()
+	if len(blocked) != 3 {
+		t.Errorf("want 3 blocked stages got %d", len(blocked))
+	}
+	if !strings.Contains(stall.Stages[2].Stack, "TestWatchdog") {
+		t.Errorf("want the stack of the busy stage got %q", stall.Stages[2].Stack)
+	}
+
+	if len(errs) != 1 {
+		t.Errorf("want the stall on the errs channel got %d errors", len(errs))
+	}
+}
+
+func TestWatchdog_noStall(t *testing.T) {
+	err := l.New().
+		SetP(func(out chan<- interface{}, errs chan<- error) {
+			for i := 0; i < 5; i++ {
+				out <- i
+				time.Sleep(time.Millisecond)
+			}
+		}).
+		Add(l.Noop).(l.Instrumenter).
+		SetWatchdog(&l.Watchdog{Timeout: time.Second, Cancel: true}).
+		Run()
+
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWatchdog_tinyTimeout(t *testing.T) {
+	// a Timeout under 4ns used to panic making the ticker
+	l.New().
+		SetP(func(out chan<- interface{}, errs chan<- error) {
+			out <- 1
+		}).
+		Add(l.Noop).(l.Instrumenter).
+		SetWatchdog(&l.Watchdog{Timeout: time.Nanosecond}).
+		Run()
+}
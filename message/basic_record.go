@@ -1,9 +1,5 @@
 package message
 
-import (
-	"encoding/json"
-)
-
 //
 // OrderedRecord implementation
 //
@@ -67,6 +63,76 @@ func (r *BasicRecord) Set(key string, val interface{}) {
 	return
 }
 
+// Delete removes the keys and their values keeping the order of the rest.
+// Keys that don't exist are ignored.
+func (r *BasicRecord) Delete(keys ...string) {
+	for _, key := range keys {
+		i, exists := r.index[key]
+		if !exists {
+			continue
+		}
+		r.Keys = append(r.Keys[:i], r.Keys[i+1:]...)
+		r.Vals = append(r.Vals[:i], r.Vals[i+1:]...)
+		delete(r.index, key)
+		for j := int(i); j < len(r.Keys); j++ {
+			r.index[r.Keys[j]] = uint(j)
+		}
+	}
+}
+
+// Merge sets the keys and values of the records on this one in turn so
+// the last value of a key wins. New keys are added in the order they are
+// in the records. It returns the record so calls can be chained.
+func (r *BasicRecord) Merge(recs ...Record) *BasicRecord {
+	for _, rec := range recs {
+		if rec == nil {
+			continue
+		}
+		for _, k := range rec.GetKeys() {
+			v, _ := rec.Get(k)
+			r.Set(k, v)
+		}
+	}
+	return r
+}
+
+// Clone returns a copy of the record that can be changed without changing
+// this one. Nested records and []interface{} values are copied as well but
+// other values like maps are shared.
+func (r BasicRecord) Clone() *BasicRecord {
+	c := &BasicRecord{
+		Keys:  append([]string{}, r.Keys...),
+		Vals:  make([]interface{}, len(r.Vals)),
+		index: make(map[string]uint, len(r.Keys)),
+	}
+	for i, k := range r.Keys {
+		c.index[k] = uint(i)
+		c.Vals[i] = cloneValue(r.Vals[i])
+	}
+	return c
+}
+
+func cloneValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case *BasicRecord:
+		if val != nil {
+			return val.Clone()
+		}
+	case BasicRecord:
+		return *val.Clone()
+	case []interface{}:
+		if val == nil {
+			return v
+		}
+		s := make([]interface{}, len(val))
+		for i, item := range val {
+			s[i] = cloneValue(item)
+		}
+		return s
+	}
+	return v
+}
+
 // Get will return the value for the specified key or nil.
 // The returned bool indicates if the key existed or not.
 func (r BasicRecord) Get(key string) (interface{}, bool) {
@@ -87,8 +153,9 @@ func (r BasicRecord) GetVals() []interface{} {
 }
 
 // String implements the fmt.Stringer interface
+// as JSON with the keys in order.
 func (r BasicRecord) String() string {
-	jsn, _ := json.Marshal(RecordToMSI(&r))
+	jsn, _ := RecordToJSON(r)
 	return string(jsn)
 }
 
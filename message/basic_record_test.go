@@ -47,3 +47,50 @@ func TestBasicRecord_Set(t *testing.T) {
 	assert(newr, 3, 1, "foo", "bar")
 	assert(newr, 3, 2, "baz", 42)
 }
+
+func TestBasicRecord_Delete(t *testing.T) {
+	r := message.NewRecordFromMSI(map[string]interface{}{"a": 1}).(*message.BasicRecord)
+	r.Set("b", 2)
+	r.Set("c", 3)
+	r.Delete("a", "nope")
+
+	if r.String() != `{"b":2,"c":3}` {
+		t.Errorf("expected a to be deleted got %s", r)
+	}
+	if v, _ := r.Get("c"); v != 3 {
+		t.Errorf("expected c to still be 3 got %v", v)
+	}
+}
+
+func TestBasicRecord_Clone(t *testing.T) {
+	nested := message.NewBasicRecord()
+	nested.Set("x", 1)
+	r := message.NewBasicRecord()
+	r.Set("nested", nested)
+	r.Set("list", []interface{}{"a"})
+
+	c := r.Clone()
+	c.Set("new", true)
+	c.Delete("list")
+	nested.Set("x", 2)
+
+	if r.String() != `{"nested":{"x":2},"list":["a"]}` {
+		t.Errorf("expected the record to be unchanged got %s", r)
+	}
+	if c.String() != `{"nested":{"x":1},"new":true}` {
+		t.Errorf("expected the clone to be changed on its own got %s", c)
+	}
+}
+
+func TestBasicRecord_Merge(t *testing.T) {
+	r := message.NewBasicRecord()
+	r.Set("a", 1)
+	r.Set("b", 2)
+	other := message.NewBasicRecord()
+	other.Set("c", 3)
+	other.Set("a", 4)
+
+	if got := r.Merge(other, nil).String(); got != `{"a":4,"b":2,"c":3}` {
+		t.Errorf("expected the values to be merged got %s", got)
+	}
+}
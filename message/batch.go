@@ -5,6 +5,12 @@ import "strings"
 // Batch is a message type that can contain a list of other messages.
 type Batch []interface{}
 
+// Batcher is a message that holds a batch of messages like an x.GroupMsg.
+type Batcher interface {
+	GetBatch() Batch
+	WithBatch(Batch) interface{} // a copy of the message holding the new batch instead
+}
+
 // String implements fmt.Stringer.
 func (b Batch) String() string {
 	strs := []string{}
@@ -18,3 +24,49 @@ func (b Batch) String() string {
 func (b Batch) Size() int {
 	return len(b)
 }
+
+// Records returns the messages in the batch that are (or wrap) a Record.
+func (b Batch) Records() []Record {
+	var recs []Record
+	for _, v := range b {
+		if r, ok := record(v); ok {
+			recs = append(recs, r)
+		}
+	}
+	return recs
+}
+
+// Split splits the batch into batches of up to n messages.
+// The batches share the backing array of the original.
+func (b Batch) Split(n int) []Batch {
+	if n < 1 || len(b) <= n {
+		return []Batch{b}
+	}
+	var batches []Batch
+	for len(b) > n {
+		batches = append(batches, b[:n:n])
+		b = b[n:]
+	}
+	return append(batches, b)
+}
+
+// ByteSize is the number of bytes of all the messages as strings.
+// This is the same size x.Batch uses for its ByteLimit.
+func (b Batch) ByteSize() int {
+	size := 0
+	for _, v := range b {
+		size += len(String(v))
+	}
+	return size
+}
+
+// record digs through wrapped messages for a Record.
+func record(m interface{}) (Record, bool) {
+	switch v := m.(type) {
+	case Record:
+		return v, true
+	case Inner:
+		return record(v.In())
+	}
+	return nil, false
+}
@@ -0,0 +1,51 @@
+package message_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+func ExampleBatch_Split() {
+	b := message.Batch{1, 2, 3, 4, 5}
+	for _, part := range b.Split(2) {
+		fmt.Println([]interface{}(part))
+	}
+	// Output:
+	// [1 2]
+	// [3 4]
+	// [5]
+}
+
+func TestBatch_Records(t *testing.T) {
+	rec := message.NewRecord()
+	rec.Set("id", 1)
+	b := message.Batch{"foo", rec, &message.Bytes{M: rec}}
+
+	recs := b.Records()
+	if len(recs) != 2 || recs[0] != rec || recs[1] != rec {
+		t.Errorf("want the record twice got %v", recs)
+	}
+}
+
+func TestBatch_ByteSize(t *testing.T) {
+	b := message.Batch{"foo", []byte("ba"), 1}
+	if size := b.ByteSize(); size != 6 {
+		t.Errorf("want 6 got %d", size)
+	}
+}
+
+func TestBatch_Split(t *testing.T) {
+	b := message.Batch{1, 2, 3}
+	if got := b.Split(3); !reflect.DeepEqual(got, []message.Batch{b}) {
+		t.Errorf("want the whole batch got %v", got)
+	}
+
+	parts := b.Split(2)
+	parts[0] = append(parts[0], "x") // mustn't overwrite the next part
+	if !reflect.DeepEqual(parts[1], message.Batch{3}) {
+		t.Errorf("want the parts kept apart got %v", parts)
+	}
+}
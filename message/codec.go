@@ -0,0 +1,361 @@
+package message
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Codec encodes messages to a stream and decodes them back as the same types.
+// The types of the messages need to be registered (see Register). Records keep
+// the order of their keys and times keep their location offset and nanoseconds.
+type Codec interface {
+	Name() string
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+// Encoder writes messages to a stream.
+type Encoder interface {
+	Encode(msg interface{}) error
+}
+
+// Decoder reads messages from a stream. Decode returns io.EOF at the end of it.
+type Decoder interface {
+	Decode() (interface{}, error)
+}
+
+// The supported codecs
+var (
+	// GobCodec uses encoding/gob.
+	GobCodec Codec = gobCodec{}
+	// JSONCodec writes a JSON object per line.
+	JSONCodec Codec = jsonCodec{}
+	// CompactCodec is a binary encoding with varints like msgpack. The type
+	// tags are only written out the first time they are used in a stream.
+	CompactCodec Codec = compactCodec{}
+)
+
+// Marshal encodes a single message with the codec.
+func Marshal(c Codec, msg interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := c.NewEncoder(&buf).Encode(msg)
+	return buf.Bytes(), err
+}
+
+// Unmarshal decodes a single message with the codec.
+func Unmarshal(c Codec, b []byte) (interface{}, error) {
+	return c.NewDecoder(bytes.NewReader(b)).Decode()
+}
+
+// nodeEncoder converts messages to nodes for the format to write.
+type nodeEncoder func(n *node) error
+
+func (enc nodeEncoder) Encode(msg interface{}) error {
+	n, err := toNode(msg)
+	if err != nil {
+		return err
+	}
+	return enc(&n)
+}
+
+// nodeDecoder converts the nodes the format read back to messages.
+type nodeDecoder func(n *node) error
+
+func (dec nodeDecoder) Decode() (interface{}, error) {
+	var n node
+	if err := dec(&n); err != nil {
+		return nil, err
+	}
+	return fromNode(n)
+}
+
+//
+// gob
+//
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) NewEncoder(w io.Writer) Encoder {
+	enc := gob.NewEncoder(w)
+	return nodeEncoder(func(n *node) error { return enc.Encode(n) })
+}
+
+func (gobCodec) NewDecoder(r io.Reader) Decoder {
+	dec := gob.NewDecoder(r)
+	return nodeDecoder(func(n *node) error { return dec.Decode(n) })
+}
+
+//
+// JSON
+//
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) NewEncoder(w io.Writer) Encoder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return nodeEncoder(func(n *node) error { return enc.Encode(n) })
+}
+
+func (jsonCodec) NewDecoder(r io.Reader) Decoder {
+	dec := json.NewDecoder(r)
+	return nodeDecoder(func(n *node) error { return dec.Decode(n) })
+}
+
+//
+// compact
+//
+
+// maxCompactLen stops a corrupt length from reading forever. Nothing is
+// allocated up front by a length so the memory used is bounded by the input.
+const maxCompactLen = 1 << 30
+
+// the bits of the mask byte for the fields of a node that are set
+const (
+	hasB byte = 1 << iota
+	hasI
+	hasU
+	hasF
+	hasS
+	hasY
+	hasK
+	hasL
+)
+
+type compactCodec struct{}
+
+func (compactCodec) Name() string { return "compact" }
+
+func (compactCodec) NewEncoder(w io.Writer) Encoder {
+	enc := &compactEncoder{w: w, tags: map[string]uint64{}}
+	return nodeEncoder(enc.encode)
+}
+
+func (compactCodec) NewDecoder(r io.Reader) Decoder {
+	dec := &compactDecoder{r: bufio.NewReader(r)}
+	return nodeDecoder(dec.decode)
+}
+
+type compactEncoder struct {
+	w    io.Writer
+	buf  bytes.Buffer
+	tags map[string]uint64
+	tmp  [binary.MaxVarintLen64]byte
+}
+
+// encode writes each message in one write.
+func (enc *compactEncoder) encode(n *node) error {
+	enc.buf.Reset()
+	enc.node(n)
+	_, err := enc.w.Write(enc.buf.Bytes())
+	return err
+}
+
+func (enc *compactEncoder) node(n *node) {
+	// a tag is its index + 1 in the table of the stream
+	// or 0 followed by the tag the first time it is used
+	if idx, ok := enc.tags[n.T]; ok {
+		enc.uvarint(idx + 1)
+	} else {
+		enc.tags[n.T] = uint64(len(enc.tags))
+		enc.uvarint(0)
+		enc.str(n.T)
+	}
+
+	var mask byte
+	if n.B {
+		mask |= hasB
+	}
+	if n.I != 0 {
+		mask |= hasI
+	}
+	if n.U != 0 {
+		mask |= hasU
+	}
+	if n.F != 0 {
+		mask |= hasF
+	}
+	if n.S != "" {
+		mask |= hasS
+	}
+	if len(n.Y) > 0 {
+		mask |= hasY
+	}
+	if len(n.K) > 0 {
+		mask |= hasK
+	}
+	if len(n.L) > 0 {
+		mask |= hasL
+	}
+	enc.buf.WriteByte(mask)
+
+	if mask&hasI != 0 {
+		enc.buf.Write(enc.tmp[:binary.PutVarint(enc.tmp[:], n.I)])
+	}
+	if mask&hasU != 0 {
+		enc.uvarint(n.U)
+	}
+	if mask&hasF != 0 {
+		binary.BigEndian.PutUint64(enc.tmp[:8], math.Float64bits(n.F))
+		enc.buf.Write(enc.tmp[:8])
+	}
+	if mask&hasS != 0 {
+		enc.str(n.S)
+	}
+	if mask&hasY != 0 {
+		enc.uvarint(uint64(len(n.Y)))
+		enc.buf.Write(n.Y)
+	}
+	if mask&hasK != 0 {
+		enc.uvarint(uint64(len(n.K)))
+		for _, k := range n.K {
+			enc.str(k)
+		}
+	}
+	if mask&hasL != 0 {
+		enc.uvarint(uint64(len(n.L)))
+		for i := range n.L {
+			enc.node(&n.L[i])
+		}
+	}
+}
+
+func (enc *compactEncoder) uvarint(x uint64) {
+	enc.buf.Write(enc.tmp[:binary.PutUvarint(enc.tmp[:], x)])
+}
+
+func (enc *compactEncoder) str(s string) {
+	enc.uvarint(uint64(len(s)))
+	enc.buf.WriteString(s)
+}
+
+type compactDecoder struct {
+	r    *bufio.Reader
+	tags []string
+}
+
+func (dec *compactDecoder) decode(n *node) error {
+	// only a clean end between messages is io.EOF
+	if _, err := dec.r.Peek(1); err != nil {
+		return err
+	}
+	err := dec.node(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (dec *compactDecoder) node(n *node) (err error) {
+	idx, err := binary.ReadUvarint(dec.r)
+	if err != nil {
+		return err
+	}
+	switch {
+	case idx == 0:
+		if n.T, err = dec.str(); err != nil {
+			return err
+		}
+		dec.tags = append(dec.tags, n.T)
+	case idx <= uint64(len(dec.tags)):
+		n.T = dec.tags[idx-1]
+	default:
+		return errors.Errorf("codec: unknown tag index %d", idx)
+	}
+
+	mask, err := dec.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	n.B = mask&hasB != 0
+	if mask&hasI != 0 {
+		if n.I, err = binary.ReadVarint(dec.r); err != nil {
+			return err
+		}
+	}
+	if mask&hasU != 0 {
+		if n.U, err = binary.ReadUvarint(dec.r); err != nil {
+			return err
+		}
+	}
+	if mask&hasF != 0 {
+		var b [8]byte
+		if _, err = io.ReadFull(dec.r, b[:]); err != nil {
+			return err
+		}
+		n.F = math.Float64frombits(binary.BigEndian.Uint64(b[:]))
+	}
+	if mask&hasS != 0 {
+		if n.S, err = dec.str(); err != nil {
+			return err
+		}
+	}
+	if mask&hasY != 0 {
+		if n.Y, err = dec.bytes(); err != nil {
+			return err
+		}
+	}
+	if mask&hasK != 0 {
+		cnt, err := dec.len()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < cnt; i++ {
+			k, err := dec.str()
+			if err != nil {
+				return err
+			}
+			n.K = append(n.K, k)
+		}
+	}
+	if mask&hasL != 0 {
+		cnt, err := dec.len()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < cnt; i++ {
+			var c node
+			if err = dec.node(&c); err != nil {
+				return err
+			}
+			n.L = append(n.L, c)
+		}
+	}
+	return nil
+}
+
+func (dec *compactDecoder) len() (int, error) {
+	l, err := binary.ReadUvarint(dec.r)
+	if err == nil && l > maxCompactLen {
+		err = errors.Errorf("codec: length %d is too long", l)
+	}
+	return int(l), err
+}
+
+func (dec *compactDecoder) bytes() ([]byte, error) {
+	l, err := dec.len()
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if _, err = io.CopyN(&b, dec.r, int64(l)); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (dec *compactDecoder) str() (string, error) {
+	b, err := dec.bytes()
+	return string(b), err
+}
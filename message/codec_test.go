@@ -0,0 +1,179 @@
+package message_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+type point struct{ X, Y int }
+
+// version only has binary methods on its pointer
+type version struct{ major, minor uint8 }
+
+func (v *version) MarshalBinary() ([]byte, error) { return []byte{v.major, v.minor}, nil }
+
+func (v *version) UnmarshalBinary(b []byte) error {
+	if len(b) != 2 {
+		return fmt.Errorf("bad len %d", len(b))
+	}
+	v.major, v.minor = b[0], b[1]
+	return nil
+}
+
+func init() {
+	if err := message.Register("message_test.point", point{}); err != nil {
+		panic(err)
+	}
+	if err := message.Register("message_test.version", &version{}); err != nil {
+		panic(err)
+	}
+}
+
+var codecs = []message.Codec{message.GobCodec, message.JSONCodec, message.CompactCodec}
+
+func TestCodec_roundTrip(t *testing.T) {
+	ts := time.Date(2020, 2, 3, 4, 5, 6, 789, time.UTC)
+
+	r := message.NewRecord()
+	r.Set("z", 1)
+	r.Set("a", "foo")
+	r.Set("m", 2.5)
+	r.Set("when", ts)
+	r.Set("none", nil)
+	r.Set("raw", []byte("bar"))
+	r.Set("list", []interface{}{int64(-3), uint8(4), true})
+	r.Set("dec", message.Decimal("1.10"))
+	r.Set("at", point{1, 2})
+
+	nested := message.NewRecord()
+	nested.Set("b", int32(7))
+	r.Set("nested", nested)
+
+	idr := idRecord("id", 1, "name", "quux")
+	env := message.NewEnvelope(message.Cmd{Name: "ls", Args: []string{"-l"}}).SetHeader("tenant", "acme")
+	env.Created, env.Ingested = ts, ts
+
+	msgs := []interface{}{
+		r,
+		*r.(*message.BasicRecord),
+		idr,
+		message.Batch{"a", 1, nil},
+		message.Event{Timestamp: ts, Source: "log", Message: "hi"},
+		message.Query{SQL: "SELECT :id", Params: idr, Dialect: message.PostgreSQL, NumberArgs: true},
+		message.Query{SQL: "SELECT ?", Args: []interface{}{1}},
+		&message.InsertDelta{Record: r, Table: "foo"},
+		&message.UpdateDelta{IDRecord: idr, Changes: nested, Table: "foo", Dialect: message.MySQL, Optimistic: true},
+		&message.DeleteDelta{IDRecord: idr, Table: "foo"},
+		&message.UpsertDelta{IDRecord: idr, Table: "foo", Style: message.UpsertMerge, UpdateCols: []string{"name"}, DoNothing: true},
+		env,
+		&point{3, 4},
+		version{1, 2},
+		&version{3, 4},
+		time.Minute,
+		map[string]interface{}{"k": "v"},
+	}
+
+	for _, c := range codecs {
+		var buf bytes.Buffer
+		enc := c.NewEncoder(&buf)
+		for _, m := range msgs {
+			if err := enc.Encode(m); err != nil {
+				t.Fatalf("%s: encoding %T: %v", c.Name(), m, err)
+			}
+		}
+
+		dec := c.NewDecoder(&buf)
+		for _, want := range msgs {
+			got, err := dec.Decode()
+			if err != nil {
+				t.Fatalf("%s: decoding %T: %v", c.Name(), want, err)
+			}
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("%s: want %#v got %#v", c.Name(), want, got)
+			}
+		}
+		if _, err := dec.Decode(); err != io.EOF {
+			t.Errorf("%s: expected io.EOF at the end got %v", c.Name(), err)
+		}
+	}
+}
+
+func TestCodec_time(t *testing.T) {
+	ts := time.Date(2020, 2, 3, 4, 5, 6, 123456789, time.FixedZone("MST", -7*60*60))
+	for _, c := range codecs {
+		b, err := message.Marshal(c, ts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := message.Unmarshal(c, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt, ok := got.(time.Time); !ok || !tt.Equal(ts) || tt.Format(time.RFC3339Nano) != ts.Format(time.RFC3339Nano) {
+			t.Errorf("%s: want %v got %v", c.Name(), ts, got)
+		}
+	}
+}
+
+func TestCodec_floats(t *testing.T) {
+	floats := []float64{math.NaN(), math.Inf(1), math.Inf(-1), math.Copysign(0, -1), 0, 1.5}
+	for _, c := range codecs {
+		for _, f := range floats {
+			for _, v := range []interface{}{f, float32(f)} {
+				b, err := message.Marshal(c, v)
+				if err != nil {
+					t.Fatalf("%s: encoding %v: %v", c.Name(), v, err)
+				}
+				got, err := message.Unmarshal(c, b)
+				if err != nil {
+					t.Fatalf("%s: decoding %v: %v", c.Name(), v, err)
+				}
+				if fmt.Sprintf("%T %v", v, v) != fmt.Sprintf("%T %v", got, got) {
+					t.Errorf("%s: want %T %v got %T %v", c.Name(), v, v, got, got)
+				}
+			}
+		}
+	}
+}
+
+func TestCodec_unregistered(t *testing.T) {
+	type unknown struct{}
+	for _, c := range codecs {
+		if _, err := message.Marshal(c, unknown{}); err == nil {
+			t.Errorf("%s: expected an error for an unregistered type", c.Name())
+		}
+	}
+	if err := message.Register("message_test.point", struct{}{}); err == nil {
+		t.Error("expected an error registering a tag twice")
+	}
+}
+
+func TestCodec_corrupt(t *testing.T) {
+	r := message.NewRecord()
+	r.Set("a", "foo")
+	r.Set("b", []interface{}{1, 2})
+	b, err := message.Marshal(message.CompactCodec, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(b); i++ {
+		if _, err := message.Unmarshal(message.CompactCodec, b[:i]); err != io.ErrUnexpectedEOF {
+			t.Errorf("%d of %d bytes: want io.ErrUnexpectedEOF got %v", i, len(b), err)
+		}
+	}
+
+	// a huge count of keys or values with nothing after it
+	for _, mask := range []byte{1 << 6, 1 << 7} {
+		corrupt := []byte{0, 1, 'x', mask, 0xff, 0xff, 0xff, 0xff, 0x03}
+		if _, err := message.Unmarshal(message.CompactCodec, corrupt); err != io.ErrUnexpectedEOF {
+			t.Errorf("mask %x: want io.ErrUnexpectedEOF got %v", mask, err)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package message
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SortKey is a key to sort records by.
+type SortKey struct {
+	Key        string // the key or a path like "user.name" (see GetPath)
+	Desc       bool   // sort from high to low
+	NullsFirst bool   // sort missing and nil values first instead of last
+}
+
+// CompareBy builds a Compare func for x.Sort that orders records by the keys
+// in turn. The messages can wrap the record (see Get) and messages without one
+// have all nil values. It returns true if x sorts before or equal to y.
+func CompareBy(keys ...SortKey) func(x, y interface{}) bool {
+	return func(x, y interface{}) bool {
+		var a, b Record
+		Get(x, &a)
+		Get(y, &b)
+		return CompareRecords(a, b, keys...) <= 0
+	}
+}
+
+// CompareRecords compares the records by the keys in turn and returns -1 if a
+// sorts before b, 1 if after and 0 if they sort the same.
+func CompareRecords(a, b Record, keys ...SortKey) int {
+	for _, k := range keys {
+		av, bv := sortValue(a, k.Key), sortValue(b, k.Key)
+
+		// nulls go first or last whatever the direction
+		switch {
+		case av == nil && bv == nil:
+			continue
+		case av == nil || bv == nil:
+			if (av == nil) == k.NullsFirst {
+				return -1
+			}
+			return 1
+		}
+
+		c := Compare(av, bv)
+		if k.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func sortValue(r Record, key string) interface{} {
+	if r == nil {
+		return nil
+	}
+	if v, ok := r.Get(key); ok {
+		return v
+	}
+	v, _ := GetPath(r, key)
+	return v
+}
+
+// Compare compares two values naturally and returns -1, 0 or 1. Numbers of
+// any type are compared by value, times by instant, strings with the numbers
+// in them compared by value (so "a2" is before "a10") and false before true.
+// Values of different kinds sort nil, bools, numbers, strings, times and then
+// anything else which is compared as formatted with %v.
+func Compare(a, b interface{}) int {
+	ar, br := compareRank(a), compareRank(b)
+	if ar != br {
+		return compareInts(int64(ar), int64(br))
+	}
+
+	switch ar {
+	case rankNil:
+		return 0
+	case rankBool:
+		return compareInts(boolInt(a.(bool)), boolInt(b.(bool)))
+	case rankNumber:
+		ai, aErr := toInt(a)
+		bi, bErr := toInt(b)
+		if aErr == nil && bErr == nil {
+			return compareInts(ai, bi)
+		}
+		af, _ := toFloat(a)
+		bf, _ := toFloat(b)
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	case rankString:
+		return naturalCompare(String(a), String(b))
+	case rankTime:
+		at, bt := a.(time.Time), b.(time.Time)
+		switch {
+		case at.Before(bt):
+			return -1
+		case at.After(bt):
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+const (
+	rankNil = iota
+	rankBool
+	rankNumber
+	rankString
+	rankTime
+	rankOther
+)
+
+func compareRank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return rankNil
+	case bool:
+		return rankBool
+	case Decimal:
+		return rankNumber
+	case string, []byte:
+		return rankString
+	case time.Time:
+		return rankTime
+	}
+	if isNumber(v) {
+		return rankNumber
+	}
+	return rankOther
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// naturalCompare compares the runs of digits in the strings by value
+// and the rest byte by byte. Strings that are the same by value like
+// "a01" and "a1" fall back to comparing the bytes.
+func naturalCompare(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			si, sj := i, j
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			na := strings.TrimLeft(a[si:i], "0")
+			nb := strings.TrimLeft(b[sj:j], "0")
+			if c := compareInts(int64(len(na)), int64(len(nb))); c != 0 {
+				return c // more digits is bigger
+			}
+			if c := strings.Compare(na, nb); c != 0 {
+				return c
+			}
+			continue
+		}
+		if a[i] != b[j] {
+			return compareInts(int64(a[i]), int64(b[j]))
+		}
+		i++
+		j++
+	}
+	if c := compareInts(int64(len(a)-i), int64(len(b)-j)); c != 0 {
+		return c
+	}
+	return bytes.Compare([]byte(a), []byte(b))
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Equal returns true if the records have the same keys with equal values
+// whatever the order of the keys. Values are compared like Diff does so
+// numbers of any type are equal by value and nested records with Equal.
+func Equal(a, b Record) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	keys := a.GetKeys()
+	if len(keys) != len(b.GetKeys()) {
+		return false
+	}
+	for _, k := range keys {
+		bv, ok := b.Get(k)
+		if !ok {
+			return false
+		}
+		av, _ := a.Get(k)
+		if !equalValues(av, bv) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package message_test
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+func ExampleCompareBy() {
+	recs := []interface{}{}
+	for _, kv := range [][]interface{}{{"b", 2}, {"a", 10}, {"a", nil}, {"a", 2.5}, {"b", 1}} {
+		r := message.NewRecord()
+		r.Set("name", kv[0])
+		r.Set("score", kv[1])
+		recs = append(recs, message.NewEnvelope(r))
+	}
+
+	less := message.CompareBy(
+		message.SortKey{Key: "name"},
+		message.SortKey{Key: "score", Desc: true, NullsFirst: true},
+	)
+	sort.SliceStable(recs, func(i, j int) bool { return !less(recs[j], recs[i]) })
+	for _, r := range recs {
+		fmt.Println(r)
+	}
+
+	// Output:
+	// {"name":"a","score":null}
+	// {"name":"a","score":10}
+	// {"name":"a","score":2.5}
+	// {"name":"b","score":2}
+	// {"name":"b","score":1}
+}
+
+func TestCompare(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		a, b interface{}
+		want int
+	}{
+		{1, int64(1), 0},
+		{uint8(2), 1.5, 1},
+		{message.Decimal("1.10"), 1.1, 0},
+		{"file2", "file10", -1},
+		{"a01", "a1", -1},
+		{"b", "a10", 1},
+		{[]byte("x"), "x", 0},
+		{now, now.Add(time.Second), -1},
+		{false, true, -1},
+		{nil, false, -1},
+		{"10", 9, 1}, // strings sort after numbers
+	}
+	for _, c := range cases {
+		if got := message.Compare(c.a, c.b); got != c.want {
+			t.Errorf("compare %v and %v: want %d got %d", c.a, c.b, c.want, got)
+		}
+		if got := message.Compare(c.b, c.a); got != -c.want {
+			t.Errorf("compare %v and %v: want %d got %d", c.b, c.a, -c.want, got)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	nested := message.NewRecord()
+	nested.Set("x", int64(1))
+	a := message.NewRecord()
+	a.Set("id", 1)
+	a.Set("nested", nested)
+
+	nested2 := message.NewRecord()
+	nested2.Set("x", 1.0)
+	b := message.NewRecord()
+	b.Set("nested", nested2)
+	b.Set("id", uint(1))
+
+	if !message.Equal(a, b) {
+		t.Errorf("expected %v to equal %v", a, b)
+	}
+	b.Set("extra", nil)
+	if message.Equal(a, b) {
+		t.Errorf("expected %v not to equal %v", a, b)
+	}
+}
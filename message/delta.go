@@ -5,10 +5,17 @@ import (
 	"strings"
 )
 
+// Delta is a change to a table that can be run as SQL.
+type Delta interface {
+	GetSQL() string
+	GetArgs() []interface{}
+}
+
 // InsertDelta is the delta type for inserts
 type InsertDelta struct {
 	Record
-	Table string
+	Table   string
+	Dialect Dialect // optional dialect of the SQL
 }
 
 // UpdateDelta is the delta type for updates
@@ -16,12 +23,18 @@ type UpdateDelta struct {
 	IDRecord        // IDRecord as we need to identify this record in the update statement
 	Changes  Record // for holding old values
 	Table    string
+	Dialect  Dialect // optional dialect of the SQL
+
+	// Optimistic adds the old values in Changes to the WHERE clause
+	// so the update only happens if the row hasn't changed since.
+	Optimistic bool
 }
 
 // DeleteDelta is the delta type for updates
 type DeleteDelta struct {
 	IDRecord // IDRecord as we need to identify this record in the delete statement
 	Table    string
+	Dialect  Dialect // optional dialect of the SQL
 }
 
 // NewInsertDelta is an insert change record related to a table
@@ -51,43 +64,58 @@ func NewDeleteDelta(r IDRecord, table string) *DeleteDelta {
 
 // GetSQL implements the SQLGetter interface
 func (d InsertDelta) GetSQL() string {
-	vals := ""
-	sep := ""
-	for range d.GetKeys() {
-		vals += sep + "?"
-		sep = ","
-	}
-	return fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`, d.Table, strings.Join(d.GetKeys(), ","), vals)
+	phs := placeholders{d: d.Dialect}
+	return fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`,
+		ident(d.Dialect, d.Table), strings.Join(idents(d.Dialect, d.GetKeys()), ","), phs.list(len(d.GetKeys())))
 }
 
-// GetSQL implements the SQLGetter interface
+// GetSQL implements the SQLGetter interface.
+// Only the columns in Changes are set if it has any.
 func (d UpdateDelta) GetSQL() string {
-	idkeys := d.GetIDKeys()
-	id := strings.Join(idkeys, "=? AND ") + "=?"
-	// get the cols without the id
-	var writeCols []string
-
-	for _, col := range d.GetKeys() {
-		exclude := false
-		for _, idkey := range idkeys {
-			if col == idkey {
-				exclude = true
-				break // skip this col as it exists in the id
+	phs := placeholders{d: d.Dialect}
+	cols := assignments(&phs, d.Dialect, d.setCols(), ", ")
+	id := assignments(&phs, d.Dialect, d.GetIDKeys(), " AND ")
+
+	if d.Optimistic && d.Changes != nil {
+		for _, col := range d.Changes.GetKeys() {
+			if v, _ := d.Changes.Get(col); v == nil {
+				id += " AND " + ident(d.Dialect, col) + " IS NULL"
+			} else {
+				id += " AND " + ident(d.Dialect, col) + "=" + phs.next()
 			}
 		}
-		if !exclude {
-			writeCols = append(writeCols, col)
-		}
 	}
-	cols := strings.Join(writeCols, "=?, ") + "=?"
 
-	return fmt.Sprintf(`UPDATE %s SET %s WHERE %s`, d.Table, cols, id)
+	return fmt.Sprintf(`UPDATE %s SET %s WHERE %s`, ident(d.Dialect, d.Table), cols, id)
+}
+
+// assignments writes col=? for each of the cols separated by sep.
+func assignments(phs *placeholders, d Dialect, cols []string, sep string) string {
+	parts := make([]string, len(cols))
+	for i, col := range cols {
+		parts[i] = ident(d, col) + "=" + phs.next()
+	}
+	return strings.Join(parts, sep)
+}
+
+// setCols gets the columns to update. These are the columns in Changes
+// or all the non-ID columns if there are no changes recorded.
+func (d UpdateDelta) setCols() []string {
+	if d.hasChanges() {
+		return d.Changes.GetKeys()
+	}
+	return GetNonIDKeys(d)
+}
+
+func (d UpdateDelta) hasChanges() bool {
+	return d.Changes != nil && len(d.Changes.GetKeys()) > 0
 }
 
 // GetSQL implements the SQLGetter interface
 func (d DeleteDelta) GetSQL() string {
-	id := strings.Join(d.GetIDKeys(), "=? AND ") + "=?"
-	return fmt.Sprintf(`DELETE FROM %s WHERE %s`, d.Table, id)
+	phs := placeholders{d: d.Dialect}
+	id := assignments(&phs, d.Dialect, d.GetIDKeys(), " AND ")
+	return fmt.Sprintf(`DELETE FROM %s WHERE %s`, ident(d.Dialect, d.Table), id)
 }
 
 // GetArgs implements the ArgGetter interface
@@ -95,19 +123,26 @@ func (d InsertDelta) GetArgs() []interface{} {
 	return d.GetVals()
 }
 
-// GetArgs implements the ArgGetter interface
-// and returns the args for the query excluding the
-// args related to the ID keys.
+// GetArgs implements the ArgGetter interface. The args are the new values
+// of the changed columns (or all the non-ID columns without Changes), the
+// ID values and the old values of the changed columns if Optimistic.
 func (d UpdateDelta) GetArgs() []interface{} {
-	// get the non-ID keys
 	vals := []interface{}{}
-	for _, key := range GetNonIDKeys(d) {
+	for _, key := range d.setCols() {
 		if val, ok := d.Get(key); ok {
 			vals = append(vals, val)
 		} else {
 			return nil // bail because we didn't find a value
 		}
 	}
+	vals = append(vals, d.GetIDVals()...)
+	if d.Optimistic && d.hasChanges() {
+		for _, key := range d.Changes.GetKeys() {
+			if old, _ := d.Changes.Get(key); old != nil {
+				vals = append(vals, old)
+			}
+		}
+	}
 	return vals
 }
 
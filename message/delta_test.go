@@ -42,9 +42,9 @@ func TestUpdateDelta(t *testing.T) {
 		t.Errorf("want '%s' got '%s'", want, d.GetSQL())
 	}
 
-	wantVals := []interface{}{vals[1], vals[2], vals[3]}
+	wantVals := []interface{}{vals[1], vals[2], vals[3], vals[0]}
 	if !reflect.DeepEqual(wantVals, d.GetArgs()) {
-		t.Errorf("want '%s' got '%s'", vals, d.GetVals())
+		t.Errorf("want '%s' got '%s'", wantVals, d.GetArgs())
 	}
 
 	// test compsite keys
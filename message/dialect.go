@@ -0,0 +1,182 @@
+package message
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialect is the flavor of SQL a database speaks. Queries and deltas with
+// a nil Dialect use ? placeholders and leave identifiers unquoted.
+type Dialect interface {
+	Name() string
+	Placeholder(n int) string      // the placeholder for the nth (starting at 1) arg
+	QuoteIdent(name string) string // quotes a table or column name
+	Literal(v interface{}) string  // renders a value inline in the SQL
+	Upsert(d UpsertDelta) string   // writes the SQL of the upsert (see UpsertDelta.StyleSQL)
+	BackslashEscapes() bool        // true if a \ escapes the next char in string literals
+}
+
+// The supported dialects
+var (
+	MySQL      Dialect = mysqlDialect{}
+	PostgreSQL Dialect = postgresDialect{}
+	SQLite     Dialect = sqliteDialect{}
+	SQLServer  Dialect = sqlserverDialect{}
+)
+
+// DialectByName gets one of the supported dialects by its name.
+func DialectByName(name string) (Dialect, bool) {
+	for _, d := range []Dialect{MySQL, PostgreSQL, SQLite, SQLServer} {
+		if d.Name() == name {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string                  { return "mysql" }
+func (mysqlDialect) Placeholder(n int) string      { return "?" }
+func (mysqlDialect) QuoteIdent(name string) string { return quoteIdent(name, "`", "`") }
+func (mysqlDialect) Upsert(d UpsertDelta) string   { return d.StyleSQL(UpsertOnDuplicateKey) }
+func (mysqlDialect) BackslashEscapes() bool        { return true }
+func (mysqlDialect) Literal(v interface{}) string {
+	if s, ok := v.(string); ok {
+		// MySQL treats backslashes as escapes in strings by default
+		return quoteString(strings.Replace(s, `\`, `\\`, -1))
+	}
+	if b, ok := v.([]byte); ok {
+		return "X'" + hex.EncodeToString(b) + "'"
+	}
+	return literal(v, "TRUE", "FALSE")
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string                  { return "postgres" }
+func (postgresDialect) Placeholder(n int) string      { return "$" + strconv.Itoa(n) }
+func (postgresDialect) QuoteIdent(name string) string { return quoteIdent(name, `"`, `"`) }
+func (postgresDialect) Upsert(d UpsertDelta) string   { return d.StyleSQL(UpsertOnConflict) }
+func (postgresDialect) BackslashEscapes() bool        { return false }
+func (postgresDialect) Literal(v interface{}) string {
+	if b, ok := v.([]byte); ok {
+		return `'\x` + hex.EncodeToString(b) + "'"
+	}
+	return literal(v, "TRUE", "FALSE")
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string                  { return "sqlite" }
+func (sqliteDialect) Placeholder(n int) string      { return "?" }
+func (sqliteDialect) QuoteIdent(name string) string { return quoteIdent(name, `"`, `"`) }
+func (sqliteDialect) Upsert(d UpsertDelta) string   { return d.StyleSQL(UpsertOnConflict) }
+func (sqliteDialect) BackslashEscapes() bool        { return false }
+func (sqliteDialect) Literal(v interface{}) string {
+	if b, ok := v.([]byte); ok {
+		return "X'" + hex.EncodeToString(b) + "'"
+	}
+	return literal(v, "1", "0")
+}
+
+type sqlserverDialect struct{}
+
+func (sqlserverDialect) Name() string                  { return "sqlserver" }
+func (sqlserverDialect) Placeholder(n int) string      { return "@p" + strconv.Itoa(n) }
+func (sqlserverDialect) QuoteIdent(name string) string { return quoteIdent(name, "[", "]") }
+func (sqlserverDialect) Upsert(d UpsertDelta) string   { return d.StyleSQL(UpsertMerge) }
+func (sqlserverDialect) BackslashEscapes() bool        { return false }
+func (sqlserverDialect) Literal(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return "N" + quoteString(s)
+	}
+	if b, ok := v.([]byte); ok {
+		return "0x" + hex.EncodeToString(b)
+	}
+	return literal(v, "1", "0")
+}
+
+// quoteIdent quotes each part of a dotted name like schema.table
+// and doubles any closing quotes in the name.
+func quoteIdent(name, open, close string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = open + strings.Replace(p, close, close+close, -1) + close
+	}
+	return strings.Join(parts, ".")
+}
+
+func quoteString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// literal renders the common SQL literals.
+func literal(v interface{}, t, f string) string {
+	switch val := v.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if val {
+			return t
+		}
+		return f
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(val)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	case Decimal:
+		return string(val)
+	case time.Time:
+		return quoteString(val.Format("2006-01-02 15:04:05.999999"))
+	case []byte:
+		return quoteString(string(val))
+	}
+	return quoteString(fmt.Sprintf("%v", v))
+}
+
+// ident quotes the name if there is a dialect.
+func ident(d Dialect, name string) string {
+	if d == nil {
+		return name
+	}
+	return d.QuoteIdent(name)
+}
+
+// idents quotes all the names if there is a dialect.
+func idents(d Dialect, names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = ident(d, name)
+	}
+	return quoted
+}
+
+// placeholders numbers the placeholders of a statement as it is written.
+type placeholders struct {
+	d Dialect
+	n int
+}
+
+// next gets the placeholder for the next arg.
+func (p *placeholders) next() string {
+	p.n++
+	if p.d == nil {
+		return "?"
+	}
+	return p.d.Placeholder(p.n)
+}
+
+// list gets the placeholders for the next cnt args separated by commas.
+func (p *placeholders) list(cnt int) string {
+	phs := make([]string, cnt)
+	for i := range phs {
+		phs[i] = p.next()
+	}
+	return strings.Join(phs, ",")
+}
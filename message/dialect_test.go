@@ -0,0 +1,143 @@
+package message_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+func TestDialect_deltas(t *testing.T) {
+	r := idRecord("id", 1, "order", 2)
+
+	cases := []struct {
+		d                      message.Dialect
+		insert, update, delete string
+	}{
+		{message.MySQL,
+			"INSERT INTO `s`.`foo` (`id`,`order`) VALUES (?,?)",
+			"UPDATE `s`.`foo` SET `order`=? WHERE `id`=?",
+			"DELETE FROM `s`.`foo` WHERE `id`=?"},
+		{message.PostgreSQL,
+			`INSERT INTO "s"."foo" ("id","order") VALUES ($1,$2)`,
+			`UPDATE "s"."foo" SET "order"=$1 WHERE "id"=$2`,
+			`DELETE FROM "s"."foo" WHERE "id"=$1`},
+		{message.SQLite,
+			`INSERT INTO "s"."foo" ("id","order") VALUES (?,?)`,
+			`UPDATE "s"."foo" SET "order"=? WHERE "id"=?`,
+			`DELETE FROM "s"."foo" WHERE "id"=?`},
+		{message.SQLServer,
+			`INSERT INTO [s].[foo] ([id],[order]) VALUES (@p1,@p2)`,
+			`UPDATE [s].[foo] SET [order]=@p1 WHERE [id]=@p2`,
+			`DELETE FROM [s].[foo] WHERE [id]=@p1`},
+	}
+	for _, c := range cases {
+		ins := message.InsertDelta{Record: r, Table: "s.foo", Dialect: c.d}
+		if got := ins.GetSQL(); got != c.insert {
+			t.Errorf("%s: want '%s' got '%s'", c.d.Name(), c.insert, got)
+		}
+		upd := message.UpdateDelta{IDRecord: r, Table: "s.foo", Dialect: c.d}
+		if got := upd.GetSQL(); got != c.update {
+			t.Errorf("%s: want '%s' got '%s'", c.d.Name(), c.update, got)
+		}
+		del := message.DeleteDelta{IDRecord: r, Table: "s.foo", Dialect: c.d}
+		if got := del.GetSQL(); got != c.delete {
+			t.Errorf("%s: want '%s' got '%s'", c.d.Name(), c.delete, got)
+		}
+	}
+}
+
+func TestDialect_upsert(t *testing.T) {
+	d := message.NewUpsertDelta(idRecord("id", 1, "name", "quux"), "foo", message.UpsertOnDuplicateKey)
+	d.Dialect = message.PostgreSQL
+
+	want := `INSERT INTO "foo" ("id","name") VALUES ($1,$2) ON CONFLICT ("id") DO UPDATE SET "name"=excluded."name"`
+	if got := d.GetSQL(); got != want {
+		t.Errorf("want '%s' got '%s'", want, got)
+	}
+
+	d.Dialect = message.SQLServer
+	want = `MERGE INTO [foo] AS target USING (SELECT @p1 AS [id], @p2 AS [name]) AS source ON target.[id]=source.[id] ` +
+		`WHEN MATCHED THEN UPDATE SET [name]=source.[name] ` +
+		`WHEN NOT MATCHED THEN INSERT ([id],[name]) VALUES (source.[id],source.[name]);`
+	if got := d.GetSQL(); got != want {
+		t.Errorf("want '%s' got '%s'", want, got)
+	}
+}
+
+// namedDialect is a custom dialect with :pN placeholders and its own upsert.
+type namedDialect struct{}
+
+func (namedDialect) Name() string                  { return "named" }
+func (namedDialect) Placeholder(n int) string      { return fmt.Sprintf(":p%d", n) }
+func (namedDialect) QuoteIdent(name string) string { return `"` + name + `"` }
+func (namedDialect) Literal(v interface{}) string  { return fmt.Sprint(v) }
+func (namedDialect) BackslashEscapes() bool        { return false }
+func (namedDialect) Upsert(d message.UpsertDelta) string {
+	return strings.Replace(d.StyleSQL(message.UpsertMerge), "MERGE INTO", "MERGE /*+ named */ INTO", 1)
+}
+
+func TestDialect_custom(t *testing.T) {
+	r := idRecord("id", 1, "name", "quux")
+
+	ins := message.InsertDelta{Record: r, Table: "foo", Dialect: namedDialect{}}
+	if want := `INSERT INTO "foo" ("id","name") VALUES (:p1,:p2)`; ins.GetSQL() != want {
+		t.Errorf("want '%s' got '%s'", want, ins.GetSQL())
+	}
+
+	up := message.UpsertDelta{IDRecord: r, Table: "foo", Dialect: namedDialect{}, DoNothing: true}
+	want := `MERGE /*+ named */ INTO "foo" AS target USING (SELECT :p1 AS "id", :p2 AS "name") AS source ON target."id"=source."id" ` +
+		`WHEN NOT MATCHED THEN INSERT ("id","name") VALUES (source."id",source."name");`
+	if got := up.GetSQL(); got != want {
+		t.Errorf("want '%s' got '%s'", want, got)
+	}
+}
+
+func TestDialect_query(t *testing.T) {
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	q := message.Query{
+		SQL:  "SELECT * FROM foo WHERE a=? AND b=? AND c=? AND d=? AND e=?",
+		Args: []interface{}{`it's \ok`, true, nil, []byte{0xab}, ts},
+	}
+
+	cases := map[message.Dialect][2]string{
+		message.MySQL: {
+			"SELECT * FROM foo WHERE a=? AND b=? AND c=? AND d=? AND e=?",
+			`SELECT * FROM foo WHERE a='it''s \\ok' AND b=TRUE AND c=NULL AND d=X'ab' AND e='2020-01-02 03:04:05'`,
+		},
+		message.PostgreSQL: {
+			"SELECT * FROM foo WHERE a=$1 AND b=$2 AND c=$3 AND d=$4 AND e=$5",
+			`SELECT * FROM foo WHERE a='it''s \ok' AND b=TRUE AND c=NULL AND d='\xab' AND e='2020-01-02 03:04:05'`,
+		},
+		message.SQLite: {
+			"SELECT * FROM foo WHERE a=? AND b=? AND c=? AND d=? AND e=?",
+			`SELECT * FROM foo WHERE a='it''s \ok' AND b=1 AND c=NULL AND d=X'ab' AND e='2020-01-02 03:04:05'`,
+		},
+		message.SQLServer: {
+			"SELECT * FROM foo WHERE a=@p1 AND b=@p2 AND c=@p3 AND d=@p4 AND e=@p5",
+			`SELECT * FROM foo WHERE a=N'it''s \ok' AND b=1 AND c=NULL AND d=0xab AND e='2020-01-02 03:04:05'`,
+		},
+	}
+	for d, want := range cases {
+		q.Dialect = d
+		if sql, _ := q.ToSQL(); sql != want[0] {
+			t.Errorf("%s: want '%s' got '%s'", d.Name(), want[0], sql)
+		}
+		if got := q.String(); got != want[1] {
+			t.Errorf("%s: want '%s' got '%s'", d.Name(), want[1], got)
+		}
+	}
+}
+
+func TestDialectByName(t *testing.T) {
+	for _, d := range []message.Dialect{message.MySQL, message.PostgreSQL, message.SQLite, message.SQLServer} {
+		if got, ok := message.DialectByName(d.Name()); !ok || got != d {
+			t.Errorf("%s not found", d.Name())
+		}
+	}
+	if _, ok := message.DialectByName("db2"); ok {
+		t.Error("expected db2 not to be found")
+	}
+}
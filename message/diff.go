@@ -0,0 +1,106 @@
+package message
+
+import (
+	"bytes"
+	"reflect"
+	"time"
+)
+
+// Diff compares the old and new versions of a record and returns the delta
+// to get the table from old to new. A nil old is an *InsertDelta, a nil new
+// is a *DeleteDelta and otherwise it is an *UpdateDelta with the old values
+// of only the changed columns in Changes. Keys missing from old are treated
+// as NULL and keys missing from new are left as is. Diff returns nil if
+// nothing changed. Both records are expected to have the same ID.
+func Diff(old, new IDRecord, table string) Delta {
+	// a nil pointer to a record is no record as well
+	if isNilRecord(old) {
+		old = nil
+	}
+	if isNilRecord(new) {
+		new = nil
+	}
+
+	switch {
+	case old == nil && new == nil:
+		return nil
+	case old == nil:
+		return NewInsertDelta(new, table)
+	case new == nil:
+		return NewDeleteDelta(old, table)
+	}
+
+	changes := NewBasicRecord()
+	for _, k := range GetNonIDKeys(new) {
+		newV, _ := new.Get(k)
+		oldV, _ := old.Get(k)
+		if !equalValues(oldV, newV) {
+			changes.Set(k, oldV)
+		}
+	}
+	if len(changes.Keys) == 0 {
+		return nil
+	}
+
+	d := NewUpdateDelta(new, table)
+	d.Changes = changes
+	return d
+}
+
+// equalValues compares values the way a database would so a value read
+// back from the database matches the one written. Numbers of any type
+// are compared by value, times by instant, []byte with strings and
+// records with Equal.
+func equalValues(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	switch av := a.(type) {
+	case Record:
+		bv, ok := b.(Record)
+		return ok && Equal(av, bv)
+	case time.Time:
+		bv, ok := b.(time.Time)
+		return ok && av.Equal(bv)
+	case []byte:
+		switch bv := b.(type) {
+		case []byte:
+			return bytes.Equal(av, bv)
+		case string:
+			return string(av) == bv
+		}
+	case string:
+		if bv, ok := b.([]byte); ok {
+			return av == string(bv)
+		}
+	}
+
+	if isNumber(a) && isNumber(b) {
+		ai, aErr := toInt(a)
+		bi, bErr := toInt(b)
+		if aErr == nil && bErr == nil {
+			return ai == bi
+		}
+		af, _ := toFloat(a)
+		bf, _ := toFloat(b)
+		return af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func isNilRecord(r IDRecord) bool {
+	if r == nil {
+		return true
+	}
+	v := reflect.ValueOf(r)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package message_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+func idRecord(kv ...interface{}) message.IDRecord {
+	r := message.NewIDRecord("id")
+	for i := 0; i < len(kv); i += 2 {
+		r.Set(kv[i].(string), kv[i+1])
+	}
+	return r
+}
+
+func TestDiff(t *testing.T) {
+	old := idRecord("id", int64(1), "name", "quux", "score", int64(5), "note", nil)
+	new := idRecord("id", 1, "name", "quux", "score", 7, "note", "hi")
+
+	d, ok := message.Diff(old, new, "foo").(*message.UpdateDelta)
+	if !ok {
+		t.Fatalf("expected an *UpdateDelta got %T", d)
+	}
+
+	want := "UPDATE foo SET score=?, note=? WHERE id=?"
+	if d.GetSQL() != want {
+		t.Errorf("want '%s' got '%s'", want, d.GetSQL())
+	}
+	wantArgs := []interface{}{7, "hi", 1}
+	if !reflect.DeepEqual(wantArgs, d.GetArgs()) {
+		t.Errorf("want %v got %v", wantArgs, d.GetArgs())
+	}
+	if got := message.String(d.Changes); got != `{"score":5,"note":null}` {
+		t.Errorf("unexpected changes %s", got)
+	}
+
+	d.Optimistic = true
+	want = "UPDATE foo SET score=?, note=? WHERE id=? AND score=? AND note IS NULL"
+	if d.GetSQL() != want {
+		t.Errorf("want '%s' got '%s'", want, d.GetSQL())
+	}
+	wantArgs = []interface{}{7, "hi", 1, int64(5)}
+	if !reflect.DeepEqual(wantArgs, d.GetArgs()) {
+		t.Errorf("want %v got %v", wantArgs, d.GetArgs())
+	}
+}
+
+func TestDiff_insertDeleteNoop(t *testing.T) {
+	r := idRecord("id", 1, "name", "quux")
+
+	if _, ok := message.Diff(nil, r, "foo").(*message.InsertDelta); !ok {
+		t.Error("expected an insert")
+	}
+	if _, ok := message.Diff(r, nil, "foo").(*message.DeleteDelta); !ok {
+		t.Error("expected a delete")
+	}
+	same := idRecord("id", 1, "name", []byte("quux"))
+	if d := message.Diff(r, same, "foo"); d != nil {
+		t.Errorf("expected no delta got %v", d.GetSQL())
+	}
+}
+
+func TestDiff_typedNil(t *testing.T) {
+	var old *message.BasicIDRecord
+	if _, ok := message.Diff(old, idRecord("id", 1), "foo").(*message.InsertDelta); !ok {
+		t.Error("expected a nil *BasicIDRecord to be an insert")
+	}
+	if d := message.Diff(old, old, "foo"); d != nil {
+		t.Errorf("expected no delta got %v", d)
+	}
+}
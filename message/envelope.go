@@ -0,0 +1,105 @@
+package message
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// Envelope wraps a message with an ID, headers and lineage so they stay
+// with the message as it moves through a pipeline. Stages that return a
+// new message for an envelope get it sent on in a child of the envelope
+// (see Child) by the line package.
+type Envelope struct {
+	ID       string
+	Headers  map[string]string
+	Created  time.Time // when the envelope was made
+	Ingested time.Time // when the first envelope of the lineage was made
+	SourceID string    // the ID of the first envelope of the lineage
+	ParentID string    // the ID of the envelope this one was made from
+	Payload  interface{}
+}
+
+// NewEnvelope wraps the message in a new envelope with a unique ID.
+func NewEnvelope(payload interface{}) *Envelope {
+	now := time.Now()
+	id := NewID()
+	return &Envelope{
+		ID:       id,
+		Headers:  map[string]string{},
+		Created:  now,
+		Ingested: now,
+		SourceID: id,
+		Payload:  payload,
+	}
+}
+
+// Child wraps a message made from this envelope's payload in a new envelope
+// with a copy of the headers and this envelope as its parent.
+func (e *Envelope) Child(payload interface{}) *Envelope {
+	headers := make(map[string]string, len(e.Headers))
+	for k, v := range e.Headers {
+		headers[k] = v
+	}
+	source := e.SourceID
+	if source == "" {
+		source = e.ID
+	}
+	return &Envelope{
+		ID:       NewID(),
+		Headers:  headers,
+		Created:  time.Now(),
+		Ingested: e.Ingested,
+		SourceID: source,
+		ParentID: e.ID,
+		Payload:  payload,
+	}
+}
+
+// SetHeader sets a header and returns the envelope to allow chaining.
+func (e *Envelope) SetHeader(key, val string) *Envelope {
+	if e.Headers == nil {
+		e.Headers = map[string]string{}
+	}
+	e.Headers[key] = val
+	return e
+}
+
+// In implements the Inner interface
+func (e *Envelope) In() interface{} {
+	return e.Payload
+}
+
+// String implements the fmt.Stringer interface with the string of the payload.
+func (e *Envelope) String() string {
+	return String(e.Payload)
+}
+
+// NewID makes a random ID in the form of a version 4 UUID.
+func NewID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// fall back on the time which is unique enough to not stop the pipeline
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+	s := hex.EncodeToString(b[:])
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+type envelopeKey struct{}
+
+// WithEnvelope adds the envelope to the context.
+func WithEnvelope(ctx context.Context, e *Envelope) context.Context {
+	return context.WithValue(ctx, envelopeKey{}, e)
+}
+
+// EnvelopeFrom gets the envelope of the message being processed from the context.
+// Stages are given the payload of an envelope and can use this to get the envelope.
+func EnvelopeFrom(ctx context.Context) (*Envelope, bool) {
+	e, ok := ctx.Value(envelopeKey{}).(*Envelope)
+	return e, ok && e != nil
+}
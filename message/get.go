@@ -9,26 +9,106 @@ type Inner interface {
 	In() interface{}
 }
 
-// Get will get the desired type out of wrapped messages.
-func Get(msg interface{}, v interface{}) bool {
-	vType := reflect.TypeOf(v)
-	return _get(msg, v, vType)
+// WalkOption changes how wrapped messages are walked.
+type WalkOption int
+
+// Walk options
+const (
+	// IntoContainers also walks the messages in a Batch or Batcher (like an x.GroupMsg).
+	IntoContainers WalkOption = iota + 1
+)
+
+// Walk calls fn with the message and then each message it wraps (see Inner)
+// until fn returns false. With IntoContainers the messages in batches are
+// walked as well in order.
+func Walk(msg interface{}, fn func(layer interface{}) bool, opts ...WalkOption) {
+	walk(msg, fn, hasOption(opts, IntoContainers))
 }
 
-// _get is the recursive digging in the wrapped messages.
-func _get(msg interface{}, v interface{}, vType reflect.Type) bool {
-	mType := reflect.TypeOf(msg)
-	pType := reflect.PtrTo(mType)
-	if mType == vType || pType == vType {
-		mVal := reflect.Indirect(reflect.ValueOf(msg))
-		vVal := reflect.Indirect(reflect.ValueOf(v))
-		vVal.Set(mVal)
-		return true
+// walk returns false if fn asked to stop.
+func walk(msg interface{}, fn func(interface{}) bool, containers bool) bool {
+	if !fn(msg) {
+		return false
 	}
 
-	if inner, ok := msg.(Inner); ok {
-		return _get(inner.In(), v, vType) // recursive call (i.e. dig deeper)
+	switch v := msg.(type) {
+	case Inner:
+		return walk(v.In(), fn, containers) // dig deeper
+	case Batch:
+		if containers {
+			return walkBatch(v, fn)
+		}
+	case Batcher:
+		if containers {
+			return walkBatch(v.GetBatch(), fn)
+		}
 	}
+	return true
+}
 
+func walkBatch(b Batch, fn func(interface{}) bool) bool {
+	for _, m := range b {
+		if !walk(m, fn, true) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasOption(opts []WalkOption, opt WalkOption) bool {
+	for _, o := range opts {
+		if o == opt {
+			return true
+		}
+	}
 	return false
 }
+
+// Get will get the desired type out of wrapped messages. v is a pointer
+// to the type wanted. If it is a pointer to an interface like
+// *fmt.Stringer the first message that implements it is got.
+func Get(msg interface{}, v interface{}, opts ...WalkOption) bool {
+	target := reflect.ValueOf(v).Elem()
+	found := false
+	Walk(msg, func(layer interface{}) bool {
+		if val, ok := match(layer, target.Type()); ok {
+			target.Set(val)
+			found = true
+		}
+		return !found
+	}, opts...)
+	return found
+}
+
+// GetAll appends every message of the desired type to the slice v points to.
+// Like with Get the type can be an interface. It returns false if none are found.
+func GetAll(msg interface{}, v interface{}, opts ...WalkOption) bool {
+	slice := reflect.ValueOf(v).Elem()
+	found := false
+	Walk(msg, func(layer interface{}) bool {
+		if val, ok := match(layer, slice.Type().Elem()); ok {
+			slice.Set(reflect.Append(slice, val))
+			found = true
+		}
+		return true
+	}, opts...)
+	return found
+}
+
+// match gets the value of the message if it is the type t, a pointer to it,
+// or implements t if t is an interface.
+func match(msg interface{}, t reflect.Type) (reflect.Value, bool) {
+	if msg == nil {
+		return reflect.Value{}, false
+	}
+	mType := reflect.TypeOf(msg)
+	switch {
+	case mType == t:
+		return reflect.ValueOf(msg), true
+	case mType == reflect.PtrTo(t) && !reflect.ValueOf(msg).IsNil():
+		return reflect.ValueOf(msg).Elem(), true
+	case t.Kind() == reflect.Interface && mType.Implements(t):
+		return reflect.ValueOf(msg), true
+	}
+	return reflect.Value{}, false
+}
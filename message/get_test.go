@@ -0,0 +1,69 @@
+package message_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+type wrapper struct{ msg interface{} }
+
+func (w wrapper) In() interface{} { return w.msg }
+
+func TestGet(t *testing.T) {
+	r := message.NewRecord()
+	r.Set("id", 1)
+	msg := message.NewEnvelope(wrapper{r})
+
+	var rec message.Record
+	if !message.Get(msg, &rec) || rec != r {
+		t.Errorf("expected the record got %v", rec)
+	}
+	var w wrapper
+	if !message.Get(msg, &w) || w.msg != r {
+		t.Errorf("expected the wrapper got %v", w)
+	}
+	var s fmt.Stringer
+	if !message.Get(msg, &s) || s != msg {
+		t.Errorf("expected the envelope as the first stringer got %v", s)
+	}
+	var br message.BasicRecord
+	if !message.Get(msg, &br) {
+		t.Error("expected the record a pointer points to")
+	}
+	var b message.Batch
+	if message.Get(msg, &b) {
+		t.Error("expected no batch")
+	}
+}
+
+func TestGetAll(t *testing.T) {
+	r1 := message.NewRecord()
+	r2 := message.NewRecord()
+	msg := message.NewEnvelope(message.Batch{wrapper{r1}, "foo", message.NewEnvelope(r2)})
+
+	var recs []message.Record
+	if message.GetAll(msg, &recs) {
+		t.Errorf("expected no records without IntoContainers got %v", recs)
+	}
+	if !message.GetAll(msg, &recs, message.IntoContainers) || !reflect.DeepEqual(recs, []message.Record{r1, r2}) {
+		t.Errorf("expected both records got %v", recs)
+	}
+
+	var rec message.Record
+	if !message.Get(msg, &rec, message.IntoContainers) || rec != r1 {
+		t.Errorf("expected the first record got %v", rec)
+	}
+
+	var layers []string
+	message.Walk(msg, func(layer interface{}) bool {
+		layers = append(layers, fmt.Sprintf("%T", layer))
+		return len(layers) < 4
+	}, message.IntoContainers)
+	want := []string{"*message.Envelope", "message.Batch", "message_test.wrapper", "*message.BasicRecord"}
+	if !reflect.DeepEqual(want, layers) {
+		t.Errorf("want %v got %v", want, layers)
+	}
+}
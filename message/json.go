@@ -0,0 +1,183 @@
+package message
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// MarshalJSON implements the json.Marshaler interface
+// with the keys in the order of the record.
+func (r BasicRecord) MarshalJSON() ([]byte, error) {
+	return RecordToJSON(r)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// The keys are kept in the order of the JSON object and any
+// nested objects are decoded as a *BasicRecord as well.
+func (r *BasicRecord) UnmarshalJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil // null leaves the record as is
+	}
+	if tok != json.Delim('{') {
+		return fmt.Errorf("message: can't unmarshal JSON %v into a record", tok)
+	}
+
+	*r = *NewBasicRecord()
+	return decodeObject(dec, r)
+}
+
+// MarshalJSON implements the json.Marshaler interface
+// with the keys in the order of the record.
+func (idr BasicIDRecord) MarshalJSON() ([]byte, error) {
+	if idr.MutableRecord == nil {
+		return []byte("null"), nil
+	}
+	return RecordToJSON(idr.MutableRecord)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// The ID keys are left as is.
+func (idr *BasicIDRecord) UnmarshalJSON(b []byte) error {
+	r := NewBasicRecord()
+	if err := r.UnmarshalJSON(b); err != nil {
+		return err
+	}
+	if idr.MutableRecord == nil {
+		idr.MutableRecord = r
+		return nil
+	}
+	for i, k := range r.Keys {
+		idr.Set(k, r.Vals[i])
+	}
+	return nil
+}
+
+// RecordToJSON converts any record to a JSON object
+// with the keys in the order of the record.
+// Records nested in the record are kept in order as well.
+func RecordToJSON(r Record) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range r.GetKeys() {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+
+		v, _ := r.Get(k)
+		val, err := valueToJSON(v)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func valueToJSON(v interface{}) ([]byte, error) {
+	switch val := v.(type) {
+	case json.Marshaler:
+		return json.Marshal(val)
+	case Record:
+		return RecordToJSON(val)
+	case []interface{}:
+		var buf bytes.Buffer
+		buf.WriteByte('[')
+		for i, item := range val {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			b, err := valueToJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(b)
+		}
+		buf.WriteByte(']')
+		return buf.Bytes(), nil
+	}
+	return json.Marshal(v)
+}
+
+// FromJSON decodes any JSON value like json.Unmarshal into an interface{}
+// except the objects are decoded as a *BasicRecord to keep the key order.
+func FromJSON(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	v, err := decodeValue(dec, tok)
+	if err != nil {
+		return nil, err
+	}
+	// only whitespace can follow the value
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("message: invalid JSON after the value")
+	}
+	return v, nil
+}
+
+// decodeObject sets the keys and values of the object on the record.
+// The opening { has already been read.
+func decodeObject(dec *json.Decoder, r *BasicRecord) error {
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("message: invalid JSON object key %v", tok)
+		}
+
+		if tok, err = dec.Token(); err != nil {
+			return err
+		}
+		val, err := decodeValue(dec, tok)
+		if err != nil {
+			return err
+		}
+		r.Set(key, val)
+	}
+	_, err := dec.Token() // the closing }
+	return err
+}
+
+// decodeValue decodes the value that starts with tok.
+func decodeValue(dec *json.Decoder, tok json.Token) (interface{}, error) {
+	switch tok {
+	case json.Delim('{'):
+		r := NewBasicRecord()
+		return r, decodeObject(dec, r)
+	case json.Delim('['):
+		arr := []interface{}{}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeValue(dec, tok)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		_, err := dec.Token() // the closing ]
+		return arr, err
+	}
+	return tok, nil
+}
@@ -0,0 +1,100 @@
+package message_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+func ExampleBasicRecord_MarshalJSON() {
+	addr := message.NewRecord()
+	addr.Set("zip", "84101")
+	addr.Set("city", "SLC")
+
+	r := message.NewRecord()
+	r.Set("name", "foo")
+	r.Set("id", 1)
+	r.Set("address", addr)
+	r.Set("tags", []interface{}{"b", "a"})
+
+	b, _ := json.Marshal(r)
+	fmt.Println(string(b))
+	// Output: {"name":"foo","id":1,"address":{"zip":"84101","city":"SLC"},"tags":["b","a"]}
+}
+
+func TestBasicRecord_UnmarshalJSON(t *testing.T) {
+	src := `{"z":1,"a":{"y":true,"b":null},"m":[{"k":"v","c":2}],"b":"x"}`
+
+	r := message.NewBasicRecord()
+	if err := json.Unmarshal([]byte(src), r); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r.GetKeys(), []string{"z", "a", "m", "b"}) {
+		t.Errorf("want the keys in order got %v", r.GetKeys())
+	}
+	nested, _ := r.Get("a")
+	if keys := nested.(*message.BasicRecord).GetKeys(); !reflect.DeepEqual(keys, []string{"y", "b"}) {
+		t.Errorf("want the nested keys in order got %v", keys)
+	}
+	if got := r.String(); got != src {
+		t.Errorf("want the json to round trip got %s", got)
+	}
+
+	var zero message.BasicRecord
+	if err := json.Unmarshal([]byte(src), &zero); err != nil {
+		t.Fatal(err)
+	}
+	zero.Set("new", 1) // the index is set up
+	if got := zero.String(); got != `{"z":1,"a":{"y":true,"b":null},"m":[{"k":"v","c":2}],"b":"x","new":1}` {
+		t.Errorf("want the zero record unmarshaled got %s", got)
+	}
+
+	if err := json.Unmarshal([]byte(`[1]`), r); err == nil {
+		t.Error("want an error for a json array")
+	}
+}
+
+func TestBasicIDRecord_JSON(t *testing.T) {
+	idr := message.NewIDRecord("id")
+	if err := json.Unmarshal([]byte(`{"name":"foo","id":7}`), idr); err != nil {
+		t.Fatal(err)
+	}
+	if id := idr.GetIDVals(); !reflect.DeepEqual(id, []interface{}{7.0}) {
+		t.Errorf("want the id 7 got %v", id)
+	}
+
+	b, err := json.Marshal(idr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"name":"foo","id":7}` {
+		t.Errorf("want the keys in order got %s", b)
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	v, err := message.FromJSON([]byte(`[{"b":1,"a":2}, 3]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr := v.([]interface{})
+	if keys := arr[0].(*message.BasicRecord).GetKeys(); !reflect.DeepEqual(keys, []string{"b", "a"}) {
+		t.Errorf("want the keys in order got %v", keys)
+	}
+	if arr[1] != 3.0 {
+		t.Errorf("want 3 got %v", arr[1])
+	}
+
+	if _, err := message.FromJSON([]byte(`{"a":1} garbage`)); err == nil {
+		t.Error("want an error for trailing garbage")
+	}
+	if _, err := message.FromJSON([]byte(" {\"a\":1}\n\t ")); err != nil {
+		t.Errorf("want whitespace around the value to be fine got %v", err)
+	}
+	if _, err := message.FromJSON([]byte(`{"a":1} {"b":2}`)); err == nil {
+		t.Error("want an error for more than one value")
+	}
+}
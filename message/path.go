@@ -0,0 +1,321 @@
+package message
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidPath is the error returned when a path can't be parsed.
+var ErrInvalidPath = errors.New("invalid path")
+
+// ErrPathType is the error returned when a path goes through a value
+// that isn't a record, map or slice, or one that can't be changed.
+var ErrPathType = errors.New("can't follow path")
+
+// pathSeg is a single step of a path.
+// It is either a key of a record or map or an index of a slice.
+type pathSeg struct {
+	key   string
+	index int
+	isIdx bool
+}
+
+func (s pathSeg) String() string {
+	if s.isIdx {
+		return fmt.Sprintf("[%d]", s.index)
+	}
+	return s.key
+}
+
+// parsePath parses a path like user.address[0].zip. Keys with dots
+// or brackets in them can be quoted in brackets like user["first.name"].
+func parsePath(path string) ([]pathSeg, error) {
+	var segs []pathSeg
+	rest := path
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, `["`):
+			end := strings.Index(rest, `"]`)
+			if end < 0 {
+				return nil, errors.Wrap(ErrInvalidPath, path)
+			}
+			segs = append(segs, pathSeg{key: rest[2:end]})
+			rest = rest[end+2:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, errors.Wrap(ErrInvalidPath, path)
+			}
+			i, err := strconv.Atoi(rest[1:end])
+			if err != nil || i < 0 {
+				return nil, errors.Wrap(ErrInvalidPath, path)
+			}
+			segs = append(segs, pathSeg{index: i, isIdx: true})
+			rest = rest[end+1:]
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, errors.Wrap(ErrInvalidPath, path)
+			}
+			segs = append(segs, pathSeg{key: rest[:end]})
+			rest = rest[end:]
+		}
+
+		// a dot has to be followed by a key
+		if strings.HasPrefix(rest, ".") {
+			rest = rest[1:]
+			if rest == "" || rest[0] == '.' || rest[0] == '[' {
+				return nil, errors.Wrap(ErrInvalidPath, path)
+			}
+		}
+	}
+	if len(segs) == 0 {
+		return nil, errors.Wrap(ErrInvalidPath, path)
+	}
+	return segs, nil
+}
+
+// GetPath gets the value at the path like "user.address[0].zip".
+// The path can go through records, maps and slices.
+// The returned bool is false if the path wasn't found.
+func GetPath(v interface{}, path string) (interface{}, bool) {
+	segs, err := parsePath(path)
+	if err != nil {
+		return nil, false
+	}
+	for _, seg := range segs {
+		var ok bool
+		if v, ok = getSeg(v, seg); !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// HasPath returns true if there is a value at the path.
+func HasPath(v interface{}, path string) bool {
+	_, ok := GetPath(v, path)
+	return ok
+}
+
+func getSeg(v interface{}, seg pathSeg) (interface{}, bool) {
+	if seg.isIdx {
+		switch c := v.(type) {
+		case []interface{}:
+			if seg.index < len(c) {
+				return c[seg.index], true
+			}
+			return nil, false
+		case Batch:
+			return getSeg([]interface{}(c), seg)
+		}
+		rv := reflect.ValueOf(v)
+		if (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && seg.index < rv.Len() {
+			return rv.Index(seg.index).Interface(), true
+		}
+	} else {
+		switch c := v.(type) {
+		case Record:
+			return c.Get(seg.key)
+		case map[string]interface{}:
+			val, ok := c[seg.key]
+			return val, ok
+		case map[interface{}]interface{}:
+			val, ok := c[seg.key]
+			return val, ok
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String {
+			val := rv.MapIndex(reflect.ValueOf(seg.key).Convert(rv.Type().Key()))
+			if val.IsValid() {
+				return val.Interface(), true
+			}
+			return nil, false
+		}
+	}
+
+	if inner, ok := v.(Inner); ok {
+		return getSeg(inner.In(), seg)
+	}
+	return nil, false
+}
+
+// SetPath sets the value at the path like "user.address[0].zip".
+// Any records, maps or slices missing along the path are created. New ones
+// are the same kind of container as the one they are in: records in records,
+// maps in maps and []interface{} for indexes. Slices are grown as needed
+// except for v itself which must already be long enough.
+func SetPath(v interface{}, path string, val interface{}) error {
+	segs, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	newV, err := setSegs(v, segs, val)
+	if err != nil {
+		return errors.Wrap(err, path)
+	}
+	if !sameContainer(v, newV) {
+		return errors.Wrapf(ErrPathType, "%s: index out of range", path)
+	}
+	return nil
+}
+
+// setSegs sets the value in the container and returns the container.
+// A slice that had to grow is returned as the new slice.
+func setSegs(v interface{}, segs []pathSeg, val interface{}) (interface{}, error) {
+	seg := segs[0]
+
+	// the value to set in this container
+	if len(segs) > 1 {
+		child, _ := getSeg(v, seg)
+		if child == nil {
+			child = newContainer(v, segs[1])
+		}
+		var err error
+		if val, err = setSegs(child, segs[1:], val); err != nil {
+			return nil, err
+		}
+	}
+
+	if seg.isIdx {
+		switch c := v.(type) {
+		case []interface{}:
+			for len(c) <= seg.index {
+				c = append(c, nil)
+			}
+			c[seg.index] = val
+			return c, nil
+		case Batch:
+			s, err := setSegs([]interface{}(c), segs[:1], val)
+			if err != nil {
+				return nil, err
+			}
+			return Batch(s.([]interface{})), nil
+		}
+	} else {
+		switch c := v.(type) {
+		case MutableRecord:
+			c.Set(seg.key, val)
+			return c, nil
+		case map[string]interface{}:
+			c[seg.key] = val
+			return c, nil
+		case map[interface{}]interface{}:
+			c[seg.key] = val
+			return c, nil
+		}
+	}
+	return nil, errors.Wrapf(ErrPathType, "%s of %T", seg, v)
+}
+
+// newContainer makes a new container for the segment inside of parent.
+func newContainer(parent interface{}, seg pathSeg) interface{} {
+	if seg.isIdx {
+		return []interface{}{}
+	}
+	switch parent.(type) {
+	case map[string]interface{}:
+		return map[string]interface{}{}
+	case map[interface{}]interface{}:
+		return map[interface{}]interface{}{}
+	}
+	return NewRecord()
+}
+
+// DeletePath removes the value at the path like "user.address[0].zip".
+// Values removed from a slice shift the rest of the slice down.
+// Nothing is done if the path doesn't exist.
+func DeletePath(v interface{}, path string) error {
+	segs, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	if len(segs) == 1 && segs[0].isIdx && reflect.ValueOf(v).Kind() == reflect.Slice {
+		return errors.Wrapf(ErrPathType, "%s: can't shrink the slice", path)
+	}
+	if _, err := deleteSegs(v, segs); err != nil {
+		return errors.Wrap(err, path)
+	}
+	return nil
+}
+
+// deleteSegs deletes the value from the container and returns the container.
+// A slice that shrunk is returned as the new slice.
+func deleteSegs(v interface{}, segs []pathSeg) (interface{}, error) {
+	seg := segs[0]
+	if len(segs) > 1 {
+		child, ok := getSeg(v, seg)
+		if !ok {
+			return v, nil // nothing to delete
+		}
+		newChild, err := deleteSegs(child, segs[1:])
+		if err != nil {
+			return nil, err
+		}
+		if sameContainer(child, newChild) {
+			return v, nil
+		}
+		return setSegs(v, segs[:1], newChild)
+	}
+
+	if seg.isIdx {
+		switch c := v.(type) {
+		case []interface{}:
+			if seg.index >= len(c) {
+				return v, nil
+			}
+			return append(c[:seg.index], c[seg.index+1:]...), nil
+		case Batch:
+			s, err := deleteSegs([]interface{}(c), segs)
+			if err != nil {
+				return nil, err
+			}
+			return Batch(s.([]interface{})), nil
+		}
+	} else {
+		switch c := v.(type) {
+		case *BasicRecord:
+			c.Delete(seg.key)
+			return c, nil
+		case *BasicIDRecord:
+			// delete from the inner record in place so the ID keys are kept
+			if _, err := deleteSegs(c.MutableRecord, segs); err != nil {
+				return nil, err
+			}
+			return c, nil
+		case map[string]interface{}:
+			delete(c, seg.key)
+			return c, nil
+		case map[interface{}]interface{}:
+			delete(c, seg.key)
+			return c, nil
+		}
+	}
+	return nil, errors.Wrapf(ErrPathType, "%s of %T", seg, v)
+}
+
+// sameContainer returns true if a and b are the same container.
+// Slices are the same if they have the same start and length.
+func sameContainer(a, b interface{}) bool {
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	if av.Kind() == reflect.Slice && bv.Kind() == reflect.Slice {
+		return av.Len() == bv.Len() && (av.Len() == 0 || av.Pointer() == bv.Pointer())
+	}
+	if av.Kind() == reflect.Map && bv.Kind() == reflect.Map {
+		return av.Pointer() == bv.Pointer()
+	}
+	if av.Type() != bv.Type() {
+		return false
+	}
+	if !av.Type().Comparable() {
+		return true // can't tell so it must have been changed in place
+	}
+	return a == b
+}
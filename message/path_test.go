@@ -0,0 +1,131 @@
+package message_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+func ExampleGetPath() {
+	user, _ := message.FromJSON([]byte(`{"user":{"address":[{"zip":"84101"}]}}`))
+
+	zip, ok := message.GetPath(user, "user.address[0].zip")
+	fmt.Println(zip, ok)
+
+	_, ok = message.GetPath(user, "user.address[1].zip")
+	fmt.Println(ok)
+	// Output:
+	// 84101 true
+	// false
+}
+
+func ExampleSetPath() {
+	r := message.NewRecord()
+	message.SetPath(r, "user.address[1].zip", "84101")
+	fmt.Println(r)
+	// Output: {"user":{"address":[null,{"zip":"84101"}]}}
+}
+
+func TestGetPath(t *testing.T) {
+	v := map[string]interface{}{
+		"a": map[interface{}]interface{}{
+			"b": []interface{}{1, map[string]interface{}{"c": "d"}},
+		},
+		"e.f":  []string{"x", "y"},
+		"type": map[string]int{"n": 1},
+	}
+
+	for path, want := range map[string]interface{}{
+		"a.b[0]":      1,
+		"a.b[1].c":    "d",
+		`["e.f"][1]`:  "y",
+		"type.n":      1,
+		`a["b"][1].c`: "d",
+	} {
+		got, ok := message.GetPath(v, path)
+		if !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: want %v got %v (%v)", path, want, got, ok)
+		}
+	}
+
+	for _, path := range []string{"a.b[2]", "a.x", "a.b[0].c", "", "a..b", "a[x]", "a.", "a[0"} {
+		if message.HasPath(v, path) {
+			t.Errorf("%s: want not found", path)
+		}
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	m := map[string]interface{}{}
+	if err := message.SetPath(m, "a.b[1]", "c"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{nil, "c"}}}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("want maps created got %v", m)
+	}
+
+	y := map[interface{}]interface{}{}
+	if err := message.SetPath(y, "a.b", 1); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(y, map[interface{}]interface{}{"a": map[interface{}]interface{}{"b": 1}}) {
+		t.Errorf("want yaml maps created got %v", y)
+	}
+
+	s := []interface{}{map[string]interface{}{}}
+	if err := message.SetPath(s, "[0].a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := message.SetPath(s, "[1]", 1); err == nil {
+		t.Error("want an error growing the top slice")
+	}
+	if err := message.SetPath(m, "a.b[1].c", 1); err == nil {
+		t.Error("want an error setting a key of a string")
+	}
+}
+
+func TestDeletePath(t *testing.T) {
+	r := message.NewRecord()
+	message.SetPath(r, "a.b", []interface{}{1, 2, 3})
+	r.Set("c", 4)
+	r.Set("d", 5)
+
+	for _, path := range []string{"a.b[1]", "c", "x.y", "a.b[9]"} {
+		if err := message.DeletePath(r, path); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+	if got := fmt.Sprint(r); got != `{"a":{"b":[1,3]},"d":5}` {
+		t.Errorf("want the paths deleted got %s", got)
+	}
+	if v, _ := r.Get("d"); v != 5 {
+		t.Errorf("want the index kept up got %v", v)
+	}
+
+	if err := message.DeletePath([]interface{}{1}, "[0]"); err == nil {
+		t.Error("want an error shrinking the top slice")
+	}
+}
+
+func TestDeletePath_idRecord(t *testing.T) {
+	child := message.NewIDRecord("id")
+	child.Set("id", 1)
+	child.Set("x", 2)
+	parent := message.NewRecord()
+	parent.Set("child", child)
+
+	if err := message.DeletePath(parent, "child.x"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := parent.Get("child")
+	idr, ok := got.(*message.BasicIDRecord)
+	if !ok {
+		t.Fatalf("want the child kept as a *BasicIDRecord got %T", got)
+	}
+	if !reflect.DeepEqual(idr.GetIDKeys(), []string{"id"}) || message.HasPath(idr, "x") {
+		t.Errorf("want the IDs kept and x deleted got %v %s", idr.GetIDKeys(), idr)
+	}
+}
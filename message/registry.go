@@ -0,0 +1,569 @@
+package message
+
+import (
+	"encoding"
+	"encoding/json"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnregistered is the error for a message type the codecs don't know.
+var ErrUnregistered = errors.New("codec: unregistered type")
+
+// node is a message converted to plain values and tagged with its type.
+// It is what the codecs actually encode. Each type only uses the fields
+// it needs so the rest are left out of the encoding.
+type node struct {
+	T string   `json:"t"`
+	B bool     `json:"b,omitempty"`
+	I int64    `json:"i,omitempty"`
+	U uint64   `json:"u,omitempty"`
+	F float64  `json:"f,omitempty"`
+	S string   `json:"s,omitempty"`
+	Y []byte   `json:"y,omitempty"`
+	K []string `json:"k,omitempty"`
+	L []node   `json:"l,omitempty"`
+}
+
+// codecType converts a registered type to and from a node.
+type codecType struct {
+	tag  string
+	typ  reflect.Type
+	to   func(v interface{}) (node, error)
+	from func(n node) (interface{}, error)
+}
+
+var registry = struct {
+	sync.RWMutex
+	byType map[reflect.Type]*codecType
+	byTag  map[string]*codecType
+}{
+	byType: map[reflect.Type]*codecType{},
+	byTag:  map[string]*codecType{},
+}
+
+// Register adds the type of the sample to the codecs with the tag. Tags have
+// to be unique and can't start with a * as that is used for pointers to a type.
+// The values are encoded as JSON unless a pointer to the type implements both
+// encoding.BinaryMarshaler and encoding.BinaryUnmarshaler. A pointer to a type
+// is registered as the type so both can be encoded.
+func Register(tag string, sample interface{}) error {
+	t := reflect.TypeOf(sample)
+	if t == nil {
+		return errors.New("codec: can't register nil")
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	to, from := userFuncs(t)
+	return register(tag, t, to, from)
+}
+
+func register(tag string, t reflect.Type, to func(interface{}) (node, error), from func(node) (interface{}, error)) error {
+	if tag == "" || strings.HasPrefix(tag, "*") {
+		return errors.Errorf("codec: invalid tag %q", tag)
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	if _, exists := registry.byTag[tag]; exists {
+		return errors.Errorf("codec: the tag %q is already registered", tag)
+	}
+	if ct, exists := registry.byType[t]; exists {
+		return errors.Errorf("codec: %s is already registered as %q", t, ct.tag)
+	}
+	ct := &codecType{tag: tag, typ: t, to: to, from: from}
+	registry.byType[t] = ct
+	registry.byTag[tag] = ct
+	return nil
+}
+
+// mustRegister registers the built in types.
+func mustRegister(tag string, sample interface{}, to func(interface{}) (node, error), from func(node) (interface{}, error)) {
+	if err := register(tag, reflect.TypeOf(sample), to, from); err != nil {
+		panic(err)
+	}
+}
+
+// toNode converts a message to a node.
+func toNode(v interface{}) (node, error) {
+	if v == nil {
+		return node{T: "nil"}, nil
+	}
+
+	registry.RLock()
+	ct, ok := registry.byType[reflect.TypeOf(v)]
+	registry.RUnlock()
+	if ok {
+		n, err := ct.to(v)
+		n.T = ct.tag
+		return n, err
+	}
+
+	// pointers are tagged with a * in front of the type they point to
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return node{T: "nil"}, nil
+		}
+		n, err := toNode(rv.Elem().Interface())
+		n.T = "*" + n.T
+		return n, err
+	}
+	return node{}, errors.Wrapf(ErrUnregistered, "%T", v)
+}
+
+// fromNode converts a node back to a message.
+func fromNode(n node) (interface{}, error) {
+	if n.T == "nil" {
+		return nil, nil
+	}
+	if strings.HasPrefix(n.T, "*") {
+		elem := n
+		elem.T = n.T[1:]
+		v, err := fromNode(elem)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		ptr := reflect.New(reflect.TypeOf(v))
+		ptr.Elem().Set(reflect.ValueOf(v))
+		return ptr.Interface(), nil
+	}
+
+	registry.RLock()
+	ct, ok := registry.byTag[n.T]
+	registry.RUnlock()
+	if !ok {
+		return nil, errors.Wrap(ErrUnregistered, n.T)
+	}
+	return ct.from(n)
+}
+
+// fromNodeAs converts the node and checks it implements the interface iface points to.
+func fromNodeAs(n node, iface interface{}) (interface{}, error) {
+	v, err := fromNode(n)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	want := reflect.TypeOf(iface).Elem()
+	if !reflect.TypeOf(v).Implements(want) {
+		return nil, errors.Errorf("codec: %s doesn't implement %s", n.T, want)
+	}
+	return v, nil
+}
+
+func toNodes(vals []interface{}) ([]node, error) {
+	nodes := make([]node, len(vals))
+	for i, v := range vals {
+		var err error
+		if nodes[i], err = toNode(v); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// fromNodes returns nil for no nodes as the codecs don't keep empty apart from nil.
+func fromNodes(nodes []node) ([]interface{}, error) {
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+	vals := make([]interface{}, len(nodes))
+	for i, n := range nodes {
+		var err error
+		if vals[i], err = fromNode(n); err != nil {
+			return nil, err
+		}
+	}
+	return vals, nil
+}
+
+// child gets the ith child of the node checking it is there.
+func child(n node, i int) (node, error) {
+	if i >= len(n.L) {
+		return node{}, errors.Errorf("codec: %s is missing values", n.T)
+	}
+	return n.L[i], nil
+}
+
+func init() {
+	// scalars
+	mustRegister("bool", false,
+		func(v interface{}) (node, error) { return node{B: v.(bool)}, nil },
+		func(n node) (interface{}, error) { return n.B, nil })
+	for _, sample := range []interface{}{int(0), int8(0), int16(0), int32(0), int64(0)} {
+		t := reflect.TypeOf(sample)
+		mustRegister(t.String(), sample,
+			func(v interface{}) (node, error) { return node{I: reflect.ValueOf(v).Int()}, nil },
+			func(n node) (interface{}, error) { return reflect.ValueOf(n.I).Convert(t).Interface(), nil })
+	}
+	for _, sample := range []interface{}{uint(0), uint8(0), uint16(0), uint32(0), uint64(0)} {
+		t := reflect.TypeOf(sample)
+		mustRegister(t.String(), sample,
+			func(v interface{}) (node, error) { return node{U: reflect.ValueOf(v).Uint()}, nil },
+			func(n node) (interface{}, error) { return reflect.ValueOf(n.U).Convert(t).Interface(), nil })
+	}
+	mustRegister("float32", float32(0),
+		func(v interface{}) (node, error) { return floatNode(float64(v.(float32))), nil },
+		func(n node) (interface{}, error) {
+			f, err := nodeFloat(n)
+			return float32(f), err
+		})
+	mustRegister("float64", float64(0),
+		func(v interface{}) (node, error) { return floatNode(v.(float64)), nil },
+		func(n node) (interface{}, error) { return nodeFloat(n) })
+	mustRegister("string", "",
+		func(v interface{}) (node, error) { return node{S: v.(string)}, nil },
+		func(n node) (interface{}, error) { return n.S, nil })
+	mustRegister("[]byte", []byte(nil),
+		func(v interface{}) (node, error) { return node{Y: v.([]byte)}, nil },
+		func(n node) (interface{}, error) { return n.Y, nil })
+	mustRegister("time.Time", time.Time{},
+		func(v interface{}) (node, error) { return node{S: v.(time.Time).Format(time.RFC3339Nano)}, nil },
+		func(n node) (interface{}, error) { return time.Parse(time.RFC3339Nano, n.S) })
+	mustRegister("time.Duration", time.Duration(0),
+		func(v interface{}) (node, error) { return node{I: int64(v.(time.Duration))}, nil },
+		func(n node) (interface{}, error) { return time.Duration(n.I), nil })
+	mustRegister("message.Decimal", Decimal(""),
+		func(v interface{}) (node, error) { return node{S: string(v.(Decimal))}, nil },
+		func(n node) (interface{}, error) { return Decimal(n.S), nil })
+
+	// containers
+	mustRegister("[]interface{}", []interface{}(nil),
+		func(v interface{}) (node, error) {
+			l, err := toNodes(v.([]interface{}))
+			return node{L: l}, err
+		},
+		func(n node) (interface{}, error) { return fromNodes(n.L) })
+	mustRegister("map[string]interface{}", map[string]interface{}(nil),
+		func(v interface{}) (node, error) {
+			m := v.(map[string]interface{})
+			n := node{K: make([]string, 0, len(m))}
+			for k := range m {
+				n.K = append(n.K, k)
+			}
+			sort.Strings(n.K) // the same map always encodes the same
+			vals := make([]interface{}, len(n.K))
+			for i, k := range n.K {
+				vals[i] = m[k]
+			}
+			var err error
+			n.L, err = toNodes(vals)
+			return n, err
+		},
+		func(n node) (interface{}, error) {
+			vals, err := fromNodes(n.L)
+			if err != nil || len(vals) != len(n.K) {
+				return nil, errors.Wrap(errOr(err, "keys and values don't match"), n.T)
+			}
+			m := make(map[string]interface{}, len(n.K))
+			for i, k := range n.K {
+				m[k] = vals[i]
+			}
+			return m, nil
+		})
+	mustRegister("message.Batch", Batch(nil),
+		func(v interface{}) (node, error) {
+			l, err := toNodes(v.(Batch))
+			return node{L: l}, err
+		},
+		func(n node) (interface{}, error) {
+			vals, err := fromNodes(n.L)
+			return Batch(vals), err
+		})
+
+	registerMessages()
+}
+
+func errOr(err error, msg string) error {
+	if err != nil {
+		return err
+	}
+	return errors.New(msg)
+}
+
+var (
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// userFuncs converts a registered user type with its binary form or JSON.
+// Which one is decided by the pointer to the type so the methods can have
+// either receiver.
+func userFuncs(t reflect.Type) (func(interface{}) (node, error), func(node) (interface{}, error)) {
+	ptrType := reflect.PtrTo(t)
+	binary := ptrType.Implements(binaryMarshalerType) && ptrType.Implements(binaryUnmarshalerType)
+
+	to := func(v interface{}) (node, error) {
+		ptr := reflect.New(t) // an addressable copy for pointer receivers
+		ptr.Elem().Set(reflect.ValueOf(v))
+		var b []byte
+		var err error
+		if binary {
+			b, err = ptr.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+		} else {
+			b, err = json.Marshal(ptr.Interface())
+		}
+		return node{Y: b}, err
+	}
+	from := func(n node) (interface{}, error) {
+		ptr := reflect.New(t)
+		var err error
+		if binary {
+			err = ptr.Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(n.Y)
+		} else {
+			err = json.Unmarshal(n.Y, ptr.Interface())
+		}
+		if err != nil {
+			return nil, err
+		}
+		return ptr.Elem().Interface(), nil
+	}
+	return to, from
+}
+
+// floatNode keeps NaN, the infinities and -0 as a string as JSON can't
+// encode them and a zero F is left out of the encodings.
+func floatNode(f float64) node {
+	if math.IsNaN(f) || math.IsInf(f, 0) || (f == 0 && math.Signbit(f)) {
+		return node{S: strconv.FormatFloat(f, 'g', -1, 64)}
+	}
+	return node{F: f}
+}
+
+func nodeFloat(n node) (float64, error) {
+	if n.S != "" {
+		return strconv.ParseFloat(n.S, 64)
+	}
+	return n.F, nil
+}
+
+// registerMessages registers the message types of this package.
+func registerMessages() {
+	mustRegister("message.BasicRecord", BasicRecord{},
+		func(v interface{}) (node, error) { return recordNode(v.(BasicRecord)) },
+		func(n node) (interface{}, error) {
+			r, err := nodeRecord(n)
+			if err != nil {
+				return nil, err
+			}
+			return *r, nil
+		})
+	mustRegister("message.BasicIDRecord", BasicIDRecord{},
+		func(v interface{}) (node, error) {
+			idr := v.(BasicIDRecord)
+			r, err := toNode(idr.MutableRecord)
+			return node{K: idr.IDKeys, L: []node{r}}, err
+		},
+		func(n node) (interface{}, error) {
+			r, err := childAs(n, 0, (*MutableRecord)(nil))
+			if err != nil {
+				return nil, err
+			}
+			idr := BasicIDRecord{IDKeys: n.K}
+			idr.MutableRecord, _ = r.(MutableRecord)
+			return idr, nil
+		})
+	mustRegister("message.Event", Event{},
+		func(v interface{}) (node, error) {
+			e := v.(Event)
+			l, err := toNodes([]interface{}{e.Timestamp, e.Source, e.Message})
+			return node{L: l}, err
+		},
+		func(n node) (interface{}, error) {
+			vals, err := fromNodes(n.L)
+			if err != nil || len(vals) != 3 {
+				return nil, errors.Wrap(errOr(err, "missing values"), n.T)
+			}
+			ts, _ := vals[0].(time.Time)
+			return Event{Timestamp: ts, Source: vals[1], Message: vals[2]}, nil
+		})
+	mustRegister("message.Cmd", Cmd{},
+		func(v interface{}) (node, error) { return node{S: v.(Cmd).Name, K: v.(Cmd).Args}, nil },
+		func(n node) (interface{}, error) { return Cmd{Name: n.S, Args: n.K}, nil })
+	mustRegister("message.Envelope", Envelope{},
+		func(v interface{}) (node, error) {
+			e := v.(Envelope)
+			n := node{S: e.ID, K: []string{e.SourceID, e.ParentID}}
+			keys := make([]string, 0, len(e.Headers))
+			for k := range e.Headers {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				n.K = append(n.K, k, e.Headers[k])
+			}
+			var err error
+			n.L, err = toNodes([]interface{}{e.Created, e.Ingested, e.Payload})
+			return n, err
+		},
+		func(n node) (interface{}, error) {
+			vals, err := fromNodes(n.L)
+			if err != nil || len(vals) != 3 || len(n.K) < 2 || len(n.K)%2 != 0 {
+				return nil, errors.Wrap(errOr(err, "missing values"), n.T)
+			}
+			e := Envelope{ID: n.S, SourceID: n.K[0], ParentID: n.K[1], Payload: vals[2]}
+			e.Created, _ = vals[0].(time.Time)
+			e.Ingested, _ = vals[1].(time.Time)
+			if len(n.K) > 2 {
+				e.Headers = make(map[string]string, (len(n.K)-2)/2)
+				for i := 2; i < len(n.K); i += 2 {
+					e.Headers[n.K[i]] = n.K[i+1]
+				}
+			}
+			return e, nil
+		})
+
+	// the Context of a query isn't encoded
+	mustRegister("message.Query", Query{},
+		func(v interface{}) (node, error) {
+			q := v.(Query)
+			l, err := toNodes([]interface{}{q.Args, q.Params})
+			return node{S: q.SQL, B: q.NumberArgs, K: []string{dialectName(q.Dialect)}, L: l}, err
+		},
+		func(n node) (interface{}, error) {
+			vals, err := fromNodes(n.L)
+			if err != nil || len(vals) != 2 {
+				return nil, errors.Wrap(errOr(err, "missing values"), n.T)
+			}
+			q := Query{SQL: n.S, NumberArgs: n.B}
+			q.Args, _ = vals[0].([]interface{})
+			q.Params, _ = vals[1].(Record)
+			q.Dialect, err = nodeDialect(n)
+			return q, err
+		})
+
+	// deltas
+	mustRegister("message.InsertDelta", InsertDelta{},
+		func(v interface{}) (node, error) {
+			d := v.(InsertDelta)
+			r, err := toNode(d.Record)
+			return node{S: d.Table, K: []string{dialectName(d.Dialect)}, L: []node{r}}, err
+		},
+		func(n node) (interface{}, error) {
+			d := InsertDelta{Table: n.S}
+			r, err := childAs(n, 0, (*Record)(nil))
+			if err != nil {
+				return nil, err
+			}
+			d.Record, _ = r.(Record)
+			d.Dialect, err = nodeDialect(n)
+			return d, err
+		})
+	mustRegister("message.UpdateDelta", UpdateDelta{},
+		func(v interface{}) (node, error) {
+			d := v.(UpdateDelta)
+			l, err := toNodes([]interface{}{d.IDRecord, d.Changes})
+			return node{S: d.Table, B: d.Optimistic, K: []string{dialectName(d.Dialect)}, L: l}, err
+		},
+		func(n node) (interface{}, error) {
+			d := UpdateDelta{Table: n.S, Optimistic: n.B}
+			r, err := childAs(n, 0, (*IDRecord)(nil))
+			if err != nil {
+				return nil, err
+			}
+			d.IDRecord, _ = r.(IDRecord)
+			changes, err := childAs(n, 1, (*Record)(nil))
+			if err != nil {
+				return nil, err
+			}
+			d.Changes, _ = changes.(Record)
+			d.Dialect, err = nodeDialect(n)
+			return d, err
+		})
+	mustRegister("message.DeleteDelta", DeleteDelta{},
+		func(v interface{}) (node, error) {
+			d := v.(DeleteDelta)
+			r, err := toNode(d.IDRecord)
+			return node{S: d.Table, K: []string{dialectName(d.Dialect)}, L: []node{r}}, err
+		},
+		func(n node) (interface{}, error) {
+			d := DeleteDelta{Table: n.S}
+			r, err := childAs(n, 0, (*IDRecord)(nil))
+			if err != nil {
+				return nil, err
+			}
+			d.IDRecord, _ = r.(IDRecord)
+			d.Dialect, err = nodeDialect(n)
+			return d, err
+		})
+	// the update columns follow the dialect name in K
+	mustRegister("message.UpsertDelta", UpsertDelta{},
+		func(v interface{}) (node, error) {
+			d := v.(UpsertDelta)
+			r, err := toNode(d.IDRecord)
+			return node{
+				S: d.Table,
+				I: int64(d.Style),
+				B: d.DoNothing,
+				K: append([]string{dialectName(d.Dialect)}, d.UpdateCols...),
+				L: []node{r},
+			}, err
+		},
+		func(n node) (interface{}, error) {
+			d := UpsertDelta{Table: n.S, Style: UpsertStyle(n.I), DoNothing: n.B}
+			r, err := childAs(n, 0, (*IDRecord)(nil))
+			if err != nil {
+				return nil, err
+			}
+			d.IDRecord, _ = r.(IDRecord)
+			if len(n.K) > 1 {
+				d.UpdateCols = n.K[1:]
+			}
+			d.Dialect, err = nodeDialect(n)
+			return d, err
+		})
+}
+
+// recordNode keeps the order of the keys with the values in the same order.
+func recordNode(r BasicRecord) (node, error) {
+	l, err := toNodes(r.Vals)
+	return node{K: r.Keys, L: l}, err
+}
+
+func nodeRecord(n node) (*BasicRecord, error) {
+	vals, err := fromNodes(n.L)
+	if err != nil || len(vals) != len(n.K) {
+		return nil, errors.Wrap(errOr(err, "keys and values don't match"), n.T)
+	}
+	r := NewBasicRecord()
+	for i, k := range n.K {
+		r.Set(k, vals[i])
+	}
+	return r, nil
+}
+
+// childAs converts the ith child of the node checking it implements the interface.
+func childAs(n node, i int, iface interface{}) (interface{}, error) {
+	c, err := child(n, i)
+	if err != nil {
+		return nil, err
+	}
+	return fromNodeAs(c, iface)
+}
+
+func dialectName(d Dialect) string {
+	if d == nil {
+		return ""
+	}
+	return d.Name()
+}
+
+// nodeDialect gets the dialect by the name in the first key of the node.
+func nodeDialect(n node) (Dialect, error) {
+	if len(n.K) == 0 || n.K[0] == "" {
+		return nil, nil
+	}
+	d, ok := DialectByName(n.K[0])
+	if !ok {
+		return nil, errors.Errorf("codec: unknown dialect %q", n.K[0])
+	}
+	return d, nil
+}
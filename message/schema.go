@@ -0,0 +1,372 @@
+package message
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field types of a Schema
+const (
+	TypeString  = "string"
+	TypeInt     = "int"     // coerced to an int64
+	TypeFloat   = "float"   // coerced to a float64
+	TypeBool    = "bool"    // coerced to a bool
+	TypeTime    = "time"    // coerced to a time.Time
+	TypeDecimal = "decimal" // coerced to a Decimal
+	TypeRecord  = "record"  // coerced to a *BasicRecord with the Field.Schema if set
+	TypeAny     = "any"     // left as is
+)
+
+// Field describes a single field of a Schema.
+type Field struct {
+	Name     string
+	Type     string      // one of the Type constants (defaults to TypeAny)
+	Layout   string      // the layout of TypeTime strings (defaults to time.RFC3339)
+	Nullable bool        // the field can be missing or nil
+	Default  interface{} // the value to use when the field is missing or nil
+	Schema   *Schema     // the schema of a TypeRecord field
+}
+
+// Schema describes the fields of a record.
+type Schema struct {
+	Fields []Field
+	Strict bool // keys not in the schema are an error instead of being passed on
+}
+
+// FieldError is the reason a single field of a record is invalid.
+type FieldError struct {
+	Field  string // the path of the field
+	Value  interface{}
+	Reason string
+}
+
+// Error implements the error interface
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Reason
+}
+
+// ValidationError is the error for a record that doesn't match a Schema.
+type ValidationError struct {
+	Record Record
+	Fields []FieldError
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	reasons := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		reasons[i] = f.Error()
+	}
+	return "invalid record: " + strings.Join(reasons, "; ")
+}
+
+// Decimal is an exact decimal number kept as its string form
+// so no precision is lost on the way to the database.
+type Decimal string
+
+// String implements the fmt.Stringer interface
+func (d Decimal) String() string {
+	return string(d)
+}
+
+// Value implements the driver.Valuer interface
+func (d Decimal) Value() (driver.Value, error) {
+	return string(d), nil
+}
+
+// Rat converts the decimal to a *big.Rat.
+func (d Decimal) Rat() (*big.Rat, bool) {
+	return new(big.Rat).SetString(string(d))
+}
+
+// Coerce converts the values of the record to the types of the schema and
+// returns them in a new record in the order of the schema. Keys not in the
+// schema are added to the end unless the schema is Strict. If any of the
+// fields are invalid, a *ValidationError with the reason for each is returned.
+func (s *Schema) Coerce(r Record) (*BasicRecord, error) {
+	out, fieldErrs := s.coerce(r, "")
+	if len(fieldErrs) > 0 {
+		return nil, &ValidationError{Record: r, Fields: fieldErrs}
+	}
+	return out, nil
+}
+
+func (s *Schema) coerce(r Record, prefix string) (*BasicRecord, []FieldError) {
+	out := NewBasicRecord()
+	var fieldErrs []FieldError
+
+	known := map[string]bool{}
+	for _, f := range s.Fields {
+		known[f.Name] = true
+		v, _ := r.Get(f.Name)
+		val, errs := f.coerce(v, prefix+f.Name)
+		if len(errs) > 0 {
+			fieldErrs = append(fieldErrs, errs...)
+			continue
+		}
+		out.Set(f.Name, val)
+	}
+
+	for _, k := range r.GetKeys() {
+		if known[k] {
+			continue
+		}
+		if s.Strict {
+			v, _ := r.Get(k)
+			fieldErrs = append(fieldErrs, FieldError{Field: prefix + k, Value: v, Reason: "not in the schema"})
+			continue
+		}
+		v, _ := r.Get(k)
+		out.Set(k, v)
+	}
+	return out, fieldErrs
+}
+
+// Coerce converts a single value to the type of the field
+// and returns a FieldError if it can't.
+func (f Field) Coerce(v interface{}) (interface{}, error) {
+	val, errs := f.coerce(v, f.Name)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return val, nil
+}
+
+// coerce converts a single value to the type of the field.
+func (f Field) coerce(v interface{}, path string) (interface{}, []FieldError) {
+	if isBlank(v, f.Type) {
+		switch {
+		case f.Default != nil:
+			v = f.Default
+		case f.Nullable:
+			return nil, nil
+		default:
+			return nil, []FieldError{{Field: path, Value: v, Reason: "is required"}}
+		}
+	}
+
+	if f.Type == TypeRecord {
+		return f.coerceRecord(v, path)
+	}
+
+	val, err := coerceValue(v, f.Type, f.Layout)
+	if err != nil {
+		return nil, []FieldError{{Field: path, Value: v, Reason: err.Error()}}
+	}
+	return val, nil
+}
+
+func (f Field) coerceRecord(v interface{}, path string) (interface{}, []FieldError) {
+	var r Record
+	switch val := v.(type) {
+	case Record:
+		r = val
+	case map[string]interface{}:
+		r = NewRecordFromMSI(val)
+	case map[interface{}]interface{}:
+		rec := NewBasicRecord()
+		for k, v := range val {
+			rec.Set(String(k), v)
+		}
+		r = rec
+	default:
+		return nil, []FieldError{{Field: path, Value: v, Reason: fmt.Sprintf("can't convert %T to a record", v)}}
+	}
+
+	if f.Schema == nil {
+		return r, nil
+	}
+	out, errs := f.Schema.coerce(r, path+".")
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return out, nil
+}
+
+// isBlank is true for missing values. Empty strings are blank for any
+// type but string as that is how a CSV has a missing value.
+func isBlank(v interface{}, typ string) bool {
+	if v == nil {
+		return true
+	}
+	if typ == TypeString || typ == TypeAny || typ == "" {
+		return false
+	}
+	switch val := v.(type) {
+	case string:
+		return strings.TrimSpace(val) == ""
+	case []byte:
+		return strings.TrimSpace(string(val)) == ""
+	}
+	return false
+}
+
+// coerceValue converts the value to the type.
+func coerceValue(v interface{}, typ, layout string) (interface{}, error) {
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+
+	switch typ {
+	case TypeAny, "":
+		return v, nil
+	case TypeString:
+		return String(v), nil
+	case TypeInt:
+		return toInt(v)
+	case TypeFloat:
+		return toFloat(v)
+	case TypeBool:
+		return toBool(v)
+	case TypeTime:
+		return toTime(v, layout)
+	case TypeDecimal:
+		return toDecimal(v)
+	}
+	return nil, fmt.Errorf("unknown type %q", typ)
+}
+
+func toInt(v interface{}) (int64, error) {
+	switch val := v.(type) {
+	case int:
+		return int64(val), nil
+	case int8:
+		return int64(val), nil
+	case int16:
+		return int64(val), nil
+	case int32:
+		return int64(val), nil
+	case int64:
+		return val, nil
+	case uint, uint8, uint16, uint32, uint64:
+		u, _ := strconv.ParseUint(fmt.Sprint(val), 10, 64)
+		if u > math.MaxInt64 {
+			return 0, fmt.Errorf("%v is out of range for an int", val)
+		}
+		return int64(u), nil
+	case float32, float64:
+		f, _ := toFloat(val)
+		if f != math.Trunc(f) || f > math.MaxInt64 || f < math.MinInt64 {
+			return 0, fmt.Errorf("can't convert %v to an int", val)
+		}
+		return int64(f), nil
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("can't convert %q to an int", val)
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("can't convert %T to an int", v)
+}
+
+// toUint converts to an unsigned int failing for negative values.
+func toUint(v interface{}) (uint64, error) {
+	switch val := v.(type) {
+	case uint:
+		return uint64(val), nil
+	case uint8:
+		return uint64(val), nil
+	case uint16:
+		return uint64(val), nil
+	case uint32:
+		return uint64(val), nil
+	case uint64:
+		return val, nil
+	}
+	i, err := toInt(v)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 {
+		return 0, fmt.Errorf("%v is negative", v)
+	}
+	return uint64(i), nil
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch val := v.(type) {
+	case float32:
+		return float64(val), nil
+	case float64:
+		return val, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		f, _ := strconv.ParseFloat(fmt.Sprint(val), 64)
+		return f, nil
+	case Decimal:
+		return toFloat(string(val))
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil {
+			return 0, fmt.Errorf("can't convert %q to a float", val)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("can't convert %T to a float", v)
+}
+
+func toBool(v interface{}) (bool, error) {
+	switch val := v.(type) {
+	case bool:
+		return val, nil
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(val))
+		if err != nil {
+			return false, fmt.Errorf("can't convert %q to a bool", val)
+		}
+		return b, nil
+	}
+	if i, err := toInt(v); err == nil && (i == 0 || i == 1) {
+		return i == 1, nil
+	}
+	return false, fmt.Errorf("can't convert %v to a bool", v)
+}
+
+func toTime(v interface{}, layout string) (time.Time, error) {
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	switch val := v.(type) {
+	case time.Time:
+		return val, nil
+	case string:
+		t, err := time.Parse(layout, strings.TrimSpace(val))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("can't convert %q to a time with the layout %q", val, layout)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("can't convert %T to a time", v)
+}
+
+func toDecimal(v interface{}) (Decimal, error) {
+	var s string
+	switch val := v.(type) {
+	case Decimal:
+		s = string(val)
+	case string:
+		s = strings.TrimSpace(val)
+	case float32:
+		s = strconv.FormatFloat(float64(val), 'f', -1, 32)
+	case float64:
+		s = strconv.FormatFloat(val, 'f', -1, 64)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s = fmt.Sprint(val)
+	default:
+		return "", fmt.Errorf("can't convert %T to a decimal", v)
+	}
+
+	// only plain decimal numbers (no fractions or exponents)
+	if strings.ContainsAny(s, "/eE") {
+		return "", fmt.Errorf("can't convert %q to a decimal", s)
+	}
+	if _, ok := new(big.Rat).SetString(s); !ok {
+		return "", fmt.Errorf("can't convert %q to a decimal", s)
+	}
+	return Decimal(s), nil
+}
@@ -0,0 +1,86 @@
+package message_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+func TestSchema_Coerce(t *testing.T) {
+	s := &message.Schema{Fields: []message.Field{
+		{Name: "id", Type: message.TypeInt},
+		{Name: "score", Type: message.TypeFloat, Nullable: true},
+		{Name: "active", Type: message.TypeBool, Default: "true"},
+		{Name: "born", Type: message.TypeTime, Layout: "2006-01-02"},
+		{Name: "price", Type: message.TypeDecimal},
+		{Name: "name", Type: message.TypeString},
+		{Name: "address", Type: message.TypeRecord, Schema: &message.Schema{Fields: []message.Field{
+			{Name: "zip", Type: message.TypeInt},
+		}}},
+	}}
+
+	r := message.NewRecord()
+	r.Set("extra", "kept")
+	r.Set("name", 42)
+	r.Set("price", "10.50")
+	r.Set("born", "2001-02-03")
+	r.Set("active", "")
+	r.Set("score", "")
+	r.Set("id", " 7 ")
+	r.Set("address", map[string]interface{}{"zip": "84101"})
+
+	got, err := s.Coerce(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantKeys := []string{"id", "score", "active", "born", "price", "name", "address", "extra"}
+	if !reflect.DeepEqual(got.GetKeys(), wantKeys) {
+		t.Errorf("want keys %v got %v", wantKeys, got.GetKeys())
+	}
+	addr := message.NewRecord()
+	addr.Set("zip", int64(84101))
+	wantVals := []interface{}{
+		int64(7), nil, true, time.Date(2001, 2, 3, 0, 0, 0, 0, time.UTC),
+		message.Decimal("10.50"), "42", addr, "kept",
+	}
+	if !reflect.DeepEqual(got.GetVals(), wantVals) {
+		t.Errorf("want vals %v got %v", wantVals, got.GetVals())
+	}
+}
+
+func TestSchema_Coerce_invalid(t *testing.T) {
+	s := &message.Schema{Strict: true, Fields: []message.Field{
+		{Name: "id", Type: message.TypeInt},
+		{Name: "ok", Type: message.TypeBool},
+		{Name: "price", Type: message.TypeDecimal},
+		{Name: "address", Type: message.TypeRecord, Schema: &message.Schema{Fields: []message.Field{
+			{Name: "zip", Type: message.TypeInt},
+		}}},
+	}}
+
+	r := message.NewRecord()
+	r.Set("ok", "maybe")
+	r.Set("price", "1e3")
+	r.Set("address", message.NewRecord())
+	r.Set("extra", 1)
+
+	_, err := s.Coerce(r)
+	verr, ok := err.(*message.ValidationError)
+	if !ok {
+		t.Fatalf("want a ValidationError got %v", err)
+	}
+
+	var fields []string
+	for _, f := range verr.Fields {
+		fields = append(fields, f.Field)
+	}
+	if want := []string{"id", "ok", "price", "address.zip", "extra"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("want errors for %v got %v", want, verr)
+	}
+	if verr.Record != r {
+		t.Error("want the invalid record in the error")
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"strings"
 )
 
 // ToSQLer defines the SQL func to extract the SQL from a message.
@@ -21,6 +20,29 @@ type Query struct {
 
 	// some drivers prefer the numbered args ($1,$2...) instead of ? for placeholders
 	NumberArgs bool
+
+	// Dialect converts the ? placeholders of the SQL for the database
+	// and renders the args in String. It takes precedence over NumberArgs.
+	Dialect Dialect
+
+	// Params are the values of :name placeholders in the SQL
+	// which are used instead of Args.
+	Params Record
+}
+
+// SQLBuilder is a ToSQLer that can report a problem with its SQL.
+type SQLBuilder interface {
+	BuildSQL() (string, []interface{}, error)
+}
+
+// BuildSQL gets the SQL and args from the message
+// and checks them if it is an SQLBuilder.
+func BuildSQL(v ToSQLer) (string, []interface{}, error) {
+	if b, ok := v.(SQLBuilder); ok {
+		return b.BuildSQL()
+	}
+	sql, args := v.ToSQL()
+	return sql, args, nil
 }
 
 // NewQuery is a constructor for a Query struct. It can support both the ?
@@ -34,8 +56,15 @@ func NewQuery(sql string, args []interface{}, numbereArgs bool) *Query {
 }
 
 // SQLResult wraps the sql.Result and adds a String func for convenient output of the results.
+// The Context is the context of the query that got the result if it had one.
 type SQLResult struct {
 	sql.Result
+	Context context.Context
+}
+
+// GetContext implements the message.ContextGetter interface
+func (r SQLResult) GetContext() context.Context {
+	return r.Context
 }
 
 // String implements the fmt.Stringer interface
@@ -47,16 +76,36 @@ func (r SQLResult) String() string {
 // String converts the query to a completed SQL string ready to run.
 // This generally should only be used in testing and debugging as
 // the mysql.Exec will properly handle the args separatly from the SQL.
+// Placeholders without an arg are left as is.
 func (q Query) String() string {
-	escaped := make([]interface{}, len(q.Args))
-	for i, v := range q.Args {
-		if v == nil {
-			escaped[i] = "NULL"
-		} else {
-			escaped[i] = "'" + strings.Replace(fmt.Sprintf("%v", v), "'", "''", -1) + "'"
+	args := q.Args
+	sql, err := rewriteParams(q.SQL, syntaxFor(q.dialect(), q.Params != nil), func(p sqlParam) (string, error) {
+		if p.name != "" {
+			v, ok := q.Params.Get(p.name)
+			if !ok {
+				return ":" + p.name, nil
+			}
+			return q.literal(v), nil
 		}
+		if p.n > len(args) {
+			return "?", nil
+		}
+		return q.literal(args[p.n-1]), nil
+	})
+	if err != nil {
+		return q.SQL
 	}
-	return fmt.Sprintf(strings.Replace(q.SQL, "?", "%v", -1), escaped...)
+	return sql
+}
+
+func (q Query) literal(v interface{}) string {
+	switch {
+	case q.Dialect != nil:
+		return q.Dialect.Literal(v)
+	case v == nil:
+		return "NULL"
+	}
+	return quoteString(fmt.Sprintf("%v", v))
 }
 
 // GetContext implements the message.ContextGetter interface
@@ -64,16 +113,27 @@ func (q Query) GetContext() context.Context {
 	return q.Context
 }
 
-// ToSQL implements the message.SQLGetter interface
+// ToSQL implements the message.SQLGetter interface.
+// The SQL and args are returned as is if they can't be built.
 func (q Query) ToSQL() (string, []interface{}) {
-	sql := q.SQL
-	if q.NumberArgs {
-		// convert to $1 arg placeholders
-		phs := []interface{}{}
-		for i := 1; i <= len(q.Args); i++ {
-			phs = append(phs, fmt.Sprintf("$%d", i))
-		}
-		sql = fmt.Sprintf(strings.Replace(q.SQL, "?", "%v", -1), phs...)
+	sql, args, err := q.BuildSQL()
+	if err != nil {
+		return q.SQL, q.Args
+	}
+	return sql, args
+}
+
+// BuildSQL implements the SQLBuilder interface. It converts the placeholders
+// for the dialect, binds the Params to :name placeholders and checks there
+// are as many args as placeholders.
+func (q Query) BuildSQL() (string, []interface{}, error) {
+	return bindParams(q.dialect(), q.SQL, q.Args, q.Params)
+}
+
+// dialect gets the dialect of the query where NumberArgs means PostgreSQL.
+func (q Query) dialect() Dialect {
+	if q.Dialect == nil && q.NumberArgs {
+		return PostgreSQL
 	}
-	return sql, q.Args
+	return q.Dialect
 }
@@ -0,0 +1,215 @@
+package message
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrArgCount is the error for SQL with a different number of placeholders than args.
+var ErrArgCount = errors.New("placeholders don't match the args")
+
+// ErrMissingParam is the error for a named parameter that isn't in the record.
+var ErrMissingParam = errors.New("missing named parameter")
+
+// sqlParam is a placeholder found in SQL.
+type sqlParam struct {
+	n    int    // the number of the placeholder starting at 1
+	name string // the name of a :name param or empty for a ?
+}
+
+// sqlSyntax is how the SQL is tokenized.
+type sqlSyntax struct {
+	backslash bool // a \ escapes the next char in strings like MySQL does by default
+	named     bool // :name is a named param
+}
+
+// syntaxFor gets the syntax of the dialect which is MySQL's if it is nil.
+// Named params are only looked for when there are params to bind.
+func syntaxFor(d Dialect, named bool) sqlSyntax {
+	return sqlSyntax{backslash: d == nil || d.BackslashEscapes(), named: named}
+}
+
+// rewriteParams walks the SQL and replaces each placeholder with what fn
+// returns. Placeholders are ? and :name outside of string literals, quoted
+// identifiers and comments. ?? is an escaped ? and ?| and ?& are left as
+// the PostgreSQL JSON operators as is :: for casts. A :name right after an
+// identifier or inside [...] like an array slice isn't a param.
+func rewriteParams(sql string, syn sqlSyntax, fn func(p sqlParam) (string, error)) (string, error) {
+	var b strings.Builder
+	n, brackets := 0, 0
+	positional, named := false, false
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			// backslashes escape in MySQL strings and PostgreSQL E'...' strings
+			backslash := syn.backslash && c != '`' ||
+				c == '\'' && i > 0 && (sql[i-1] == 'E' || sql[i-1] == 'e') && (i == 1 || !isParamChar(sql[i-2]))
+			end := closeQuote(sql, i+1, c, backslash)
+			b.WriteString(sql[i:end])
+			i = end
+
+		case c == '[' || c == ']':
+			if c == '[' {
+				brackets++
+			} else if brackets > 0 {
+				brackets--
+			}
+			b.WriteByte(c)
+			i++
+
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			b.WriteString(sql[i : i+end])
+			i += end
+
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				end = len(sql) - i
+			} else {
+				end += 4
+			}
+			b.WriteString(sql[i : i+end])
+			i += end
+
+		case c == '$' && dollarTag(sql[i:]) != "":
+			tag := dollarTag(sql[i:])
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end < 0 {
+				end = len(sql) - i
+			} else {
+				end += 2 * len(tag)
+			}
+			b.WriteString(sql[i : i+end])
+			i += end
+
+		case c == '?':
+			if i+1 < len(sql) && strings.IndexByte("?|&", sql[i+1]) >= 0 {
+				if sql[i+1] == '?' {
+					b.WriteByte('?') // escaped
+				} else {
+					b.WriteString(sql[i : i+2])
+				}
+				i += 2
+				continue
+			}
+			n++
+			positional = true
+			ph, err := fn(sqlParam{n: n})
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(ph)
+			i++
+
+		case c == ':' && syn.named && brackets == 0 && i+1 < len(sql) && isParamStart(sql[i+1]) &&
+			(i == 0 || sql[i-1] != ':' && !isParamChar(sql[i-1])):
+			end := i + 1
+			for end < len(sql) && isParamChar(sql[end]) {
+				end++
+			}
+			n++
+			named = true
+			ph, err := fn(sqlParam{n: n, name: sql[i+1 : end]})
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(ph)
+			i = end
+
+		case c == ':' && strings.HasPrefix(sql[i:], "::"):
+			b.WriteString("::")
+			i += 2
+
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+
+	if positional && named {
+		return "", errors.New("can't mix ? and :name placeholders")
+	}
+	return b.String(), nil
+}
+
+// closeQuote finds the end of the quoted string starting at i.
+// A doubled quote is an escaped quote as is a \ escaped one with backslash.
+func closeQuote(sql string, i int, q byte, backslash bool) int {
+	for i < len(sql) {
+		if backslash && sql[i] == '\\' {
+			i += 2
+			continue
+		}
+		if sql[i] == q {
+			if i+1 < len(sql) && sql[i+1] == q {
+				i += 2
+				continue
+			}
+			return i + 1
+		}
+		i++
+	}
+	return len(sql)
+}
+
+// dollarTag gets the $tag$ that starts a PostgreSQL dollar quoted string.
+func dollarTag(sql string) string {
+	for i := 1; i < len(sql); i++ {
+		if sql[i] == '$' {
+			return sql[:i+1]
+		}
+		if !isParamChar(sql[i]) || (i == 1 && sql[i] >= '0' && sql[i] <= '9') {
+			return "" // $1 is a placeholder, not a tag
+		}
+	}
+	return ""
+}
+
+func isParamStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isParamChar(c byte) bool {
+	return isParamStart(c) || (c >= '0' && c <= '9')
+}
+
+// bindParams rewrites the placeholders of the SQL for the dialect and gets
+// the args for them. Named params get their values from the record and
+// :name is left as is without one.
+func bindParams(d Dialect, sql string, args []interface{}, params Record) (string, []interface{}, error) {
+	phs := placeholders{d: d}
+	var bound []interface{}
+
+	out, err := rewriteParams(sql, syntaxFor(d, params != nil), func(p sqlParam) (string, error) {
+		if p.name == "" {
+			return phs.next(), nil
+		}
+		v, ok := params.Get(p.name)
+		if !ok {
+			return "", errors.Wrap(ErrMissingParam, p.name)
+		}
+		bound = append(bound, v)
+		return phs.next(), nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	if bound != nil {
+		if len(args) > 0 {
+			return "", nil, errors.New("can't use args with :name placeholders")
+		}
+		return out, bound, nil
+	}
+	if phs.n != len(args) {
+		return "", nil, errors.Wrapf(ErrArgCount, "%d placeholders and %d args", phs.n, len(args))
+	}
+	return out, args, nil
+}
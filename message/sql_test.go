@@ -0,0 +1,129 @@
+package message_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+func TestQuery_placeholders(t *testing.T) {
+	sql := `SELECT '?', "a?", ` + "`b?`" + `, data ?| array['x'], data ?? 'k', x::int, 100% ` +
+		`-- what?
+		/* why? */ $$ ? $$ FROM foo WHERE id=? AND name=?`
+	q := message.Query{SQL: sql, Args: []interface{}{1, "it's"}, NumberArgs: true}
+
+	wantSQL := `SELECT '?', "a?", ` + "`b?`" + `, data ?| array['x'], data ? 'k', x::int, 100% ` +
+		`-- what?
+		/* why? */ $$ ? $$ FROM foo WHERE id=$1 AND name=$2`
+	got, args := q.ToSQL()
+	if got != wantSQL {
+		t.Errorf("want '%s' got '%s'", wantSQL, got)
+	}
+	if !reflect.DeepEqual(args, q.Args) {
+		t.Errorf("want %v got %v", q.Args, args)
+	}
+
+	wantStr := `SELECT '?', "a?", ` + "`b?`" + `, data ?| array['x'], data ? 'k', x::int, 100% ` +
+		`-- what?
+		/* why? */ $$ ? $$ FROM foo WHERE id='1' AND name='it''s'`
+	if q.String() != wantStr {
+		t.Errorf("want '%s' got '%s'", wantStr, q.String())
+	}
+}
+
+func TestQuery_named(t *testing.T) {
+	params := message.NewRecord()
+	params.Set("id", 7)
+	params.Set("name", "quux")
+	q := message.Query{
+		SQL:     "UPDATE foo SET name=:name, at='12:30' WHERE id=:id AND id::text=:id",
+		Params:  params,
+		Dialect: message.SQLServer,
+	}
+
+	sql, args, err := q.BuildSQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "UPDATE foo SET name=@p1, at='12:30' WHERE id=@p2 AND id::text=@p3"
+	if sql != want {
+		t.Errorf("want '%s' got '%s'", want, sql)
+	}
+	if wantArgs := []interface{}{"quux", 7, 7}; !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("want %v got %v", wantArgs, args)
+	}
+	want = "UPDATE foo SET name=N'quux', at='12:30' WHERE id=7 AND id::text=7"
+	if q.String() != want {
+		t.Errorf("want '%s' got '%s'", want, q.String())
+	}
+}
+
+func TestQuery_errors(t *testing.T) {
+	q := message.Query{SQL: "SELECT * FROM foo WHERE a=? AND b=?", Args: []interface{}{1}}
+	if _, _, err := q.BuildSQL(); errors.Cause(err) != message.ErrArgCount {
+		t.Errorf("expected ErrArgCount got %v", err)
+	}
+	if sql, args := q.ToSQL(); sql != q.SQL || len(args) != 1 {
+		t.Errorf("expected the query as is got '%s' %v", sql, args)
+	}
+
+	q = message.Query{SQL: "SELECT * FROM foo WHERE a=:a", Params: message.NewRecord()}
+	if _, _, err := q.BuildSQL(); errors.Cause(err) != message.ErrMissingParam {
+		t.Errorf("expected ErrMissingParam got %v", err)
+	}
+
+	q = message.Query{SQL: "SELECT * FROM foo WHERE a=:a AND b=?", Args: []interface{}{1}, Params: message.NewRecord()}
+	if _, _, err := q.BuildSQL(); err == nil {
+		t.Error("expected an error for mixed placeholders")
+	}
+}
+
+func TestQuery_backslashEscapes(t *testing.T) {
+	q := message.Query{SQL: `SELECT * FROM t WHERE name = 'O\'Brien?' AND id = ?`, Args: []interface{}{1}}
+	want := `SELECT * FROM t WHERE name = 'O\'Brien?' AND id = '1'`
+	if q.String() != want {
+		t.Errorf("want '%s' got '%s'", want, q.String())
+	}
+	if _, _, err := q.BuildSQL(); err != nil {
+		t.Error(err)
+	}
+
+	// only E'...' strings have backslash escapes in PostgreSQL
+	q = message.Query{SQL: `SELECT 'a\' || ?, E'b\'?' || ?`, Args: []interface{}{1, 2}, Dialect: message.PostgreSQL}
+	sql, _, err := q.BuildSQL()
+	if want := `SELECT 'a\' || $1, E'b\'?' || $2`; err != nil || sql != want {
+		t.Errorf("want '%s' got '%s' (%v)", want, sql, err)
+	}
+}
+
+// mariaDB is a custom dialect that is MySQL compatible.
+type mariaDB struct{ message.Dialect }
+
+func (mariaDB) Name() string { return "mariadb" }
+
+func TestQuery_customBackslashEscapes(t *testing.T) {
+	q := message.Query{SQL: `SELECT 'O\'Brien?', ?`, Args: []interface{}{1}, Dialect: mariaDB{message.MySQL}}
+	if want := `SELECT 'O\'Brien?', 1`; q.String() != want {
+		t.Errorf("want '%s' got '%s'", want, q.String())
+	}
+}
+
+func TestQuery_colons(t *testing.T) {
+	q := message.Query{SQL: "SELECT arr[lo:hi], ts AT TIME ZONE 'UTC' FROM t WHERE id = ?", Args: []interface{}{1}}
+	sql, args, err := q.BuildSQL()
+	if err != nil || sql != q.SQL || len(args) != 1 {
+		t.Errorf("expected the query as is got '%s' %v (%v)", sql, args, err)
+	}
+
+	// with params :name is only a param when it isn't part of something else
+	params := message.NewRecord()
+	params.Set("hi", 2)
+	q = message.Query{SQL: "SELECT arr[lo:hi], x:hi FROM t WHERE id = :hi", Params: params}
+	sql, args, err = q.BuildSQL()
+	if want := "SELECT arr[lo:hi], x:hi FROM t WHERE id = ?"; err != nil || sql != want || len(args) != 1 {
+		t.Errorf("want '%s' got '%s' %v (%v)", want, sql, args, err)
+	}
+}
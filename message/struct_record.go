@@ -1,7 +1,12 @@
 package message
 
 import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
 	"reflect"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -10,74 +15,242 @@ import (
 // based on the given tag lookup as the "Keys". For example: many
 // db drivers use a 'db' tag on struct fields to know how to translate
 // to the database column. the GetKeys() call of this returns the 'db' tag values.
+//
+// Tags can have options after the name like `db:"name,omitempty"`.
+// A tag of "-" skips the field and unexported fields are always skipped.
+// The fields of embedded structs are flattened into the record unless the
+// embedded struct has a tag name of its own. Zero valued omitempty fields
+// are left out of GetKeys and GetVals but can still be Get.
+//
 // You should always use the NewStructRecord constructor to create this.
 type StructRecord struct {
-	tagName    string
-	record     interface{} // record holds the struct to do the tag lookup on
-	tags       []string
-	tagsToName map[string]string
+	tagName string
+	record  interface{}   // record holds the struct to do the tag lookup on
+	val     reflect.Value // the struct that is read and set
+	keys    []string
+	fields  map[string]structField
+}
+
+// structField is a field of the struct or of a struct in it.
+type structField struct {
+	index     []int
+	omitempty bool
+	depth     int
 }
 
 // ErrNotAStruct is for when the provided arg is not a struct
 var ErrNotAStruct = errors.New("not a struct")
 
+// ErrUnknownField is for when a key isn't a field of the struct
+var ErrUnknownField = errors.New("unknown field")
+
 // NewStructRecord createa a new StructRecord. Thee tagName arg
 // is optional and will be used instead of the default field name.
 // While the tagName arg is a slice, only the [0] value is used.
+// A pointer to a struct can be changed with Set.
 func NewStructRecord(strct interface{}, tagName ...string) (StructRecord, error) {
+	return newStructRecord(strct, false, tagName)
+}
+
+// NewNestedStructRecord is like NewStructRecord but the fields of nested
+// structs are in the record as dotted paths like "address.zip".
+// Times and types that are an sql.Scanner or driver.Valuer aren't nested.
+func NewNestedStructRecord(strct interface{}, tagName ...string) (StructRecord, error) {
+	return newStructRecord(strct, true, tagName)
+}
+
+func newStructRecord(strct interface{}, nested bool, tagName []string) (StructRecord, error) {
 	tag := ""
 	if len(tagName) > 0 {
 		tag = tagName[0]
 	}
 
 	// ensure that it is a struct we are working with
-	t := reflect.TypeOf(strct)
-	if t.Kind() == reflect.Ptr {
-		t = t.Elem() // get the type the pointer points to
+	v := reflect.ValueOf(strct)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return StructRecord{}, ErrNotAStruct
+		}
+		v = v.Elem() // get the value the pointer points to
+	} else if v.Kind() == reflect.Struct {
+		// copy the struct so Set works on a struct passed by value as well
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(v)
+		v = cp
 	}
-	if t.Kind() != reflect.Struct {
+	if v.Kind() != reflect.Struct {
 		return StructRecord{}, ErrNotAStruct
 	}
 
-	// extract the tags
-	tags := []string{}
-	tagsToName := map[string]string{}
+	rec := StructRecord{tagName: tag, record: strct, val: v, fields: map[string]structField{}}
+	var found []string
+	collectFields(v.Type(), tag, nested, nil, "", rec.fields, &found)
+
+	// keep the keys in the order of the fields
+	seen := map[string]bool{}
+	for _, key := range found {
+		if !seen[key] && !isNestable(fieldType(v.Type(), rec.fields[key].index), nested) {
+			rec.keys = append(rec.keys, key)
+		}
+		seen[key] = true
+	}
+	return rec, nil
+}
+
+// collectFields adds the fields of the struct type to fields.
+// The field the fewest embedded structs down wins if two have the same key.
+func collectFields(t reflect.Type, tag string, nested bool, index []int, prefix string, fields map[string]structField, found *[]string) {
 	for i := 0; i < t.NumField(); i++ {
 		f := t.Field(i)
-		tagVal := f.Name
+		tagVal := ""
 		if tag != "" {
 			tagVal = f.Tag.Get(tag)
 		}
-		tags = append(tags, tagVal)
-		tagsToName[tagVal] = f.Name
+		if tagVal == "-" {
+			continue
+		}
+		name, opts := tagVal, ""
+		if comma := strings.IndexByte(tagVal, ','); comma >= 0 {
+			name, opts = tagVal[:comma], tagVal[comma+1:]
+		}
+
+		idx := append(append([]int{}, index...), i)
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			if f.PkgPath != "" && f.Type.Kind() == reflect.Ptr {
+				continue // can't set or read through an unexported pointer
+			}
+			collectFields(ft, tag, nested, idx, prefix, fields, found)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		key := prefix + name
+		sf := structField{index: idx, depth: len(idx), omitempty: hasOpt(opts, "omitempty")}
+		if prev, exists := fields[key]; !exists || sf.depth < prev.depth {
+			fields[key] = sf
+		}
+		*found = append(*found, key)
+
+		if isNestable(f.Type, nested) {
+			collectFields(ft, tag, nested, idx, key+".", fields, found)
+		}
 	}
+}
 
-	rec := StructRecord{tagName: tag, record: strct, tags: tags, tagsToName: tagsToName}
-	return rec, nil
+func hasOpt(opts, opt string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// isNestable is true for struct fields that are flattened into dotted paths.
+func isNestable(t reflect.Type, nested bool) bool {
+	if !nested {
+		return false
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType &&
+		!t.Implements(valuerType) && !reflect.PtrTo(t).Implements(scannerType)
+}
+
+func fieldType(t reflect.Type, index []int) reflect.Type {
+	for _, i := range index {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		t = t.Field(i).Type
+	}
+	return t
+}
+
+// field gets the field at the index. Nil pointers on the way are
+// allocated if alloc is true or else the field isn't valid.
+func field(v reflect.Value, index []int, alloc bool) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
 }
 
 // In implements the Inner interface
 // and returns the original struct that this wraps.
+// A struct that wasn't passed as a pointer is returned with any changes from Set.
 func (sr StructRecord) In() interface{} {
-	return sr.record
+	if reflect.TypeOf(sr.record).Kind() == reflect.Ptr {
+		return sr.record
+	}
+	return sr.val.Interface()
 }
 
 // Get implements the Record interface
 func (sr StructRecord) Get(key string) (interface{}, bool) {
-	if name, ok := sr.tagsToName[key]; ok {
-		r := reflect.ValueOf(sr.record)
-		return reflect.Indirect(r).FieldByName(name).Interface(), true
+	sf, ok := sr.fields[key]
+	if !ok {
+		return nil, false
 	}
-	return nil, false
+	f := field(sr.val, sf.index, false)
+	if !f.IsValid() {
+		return nil, true // in a nil struct pointer
+	}
+	return f.Interface(), true
 }
 
 // GetKeys implements the Record interface
-func (sr StructRecord) GetKeys() []string { return sr.tags }
+func (sr StructRecord) GetKeys() []string {
+	keys := make([]string, 0, len(sr.keys))
+	for _, key := range sr.keys {
+		if sr.omitted(key) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// omitted is true for a zero valued omitempty field.
+func (sr StructRecord) omitted(key string) bool {
+	sf := sr.fields[key]
+	if !sf.omitempty {
+		return false
+	}
+	f := field(sr.val, sf.index, false)
+	return !f.IsValid() || f.IsZero()
+}
 
 // GetVals implements the Record interface
 func (sr StructRecord) GetVals() []interface{} {
 	vals := []interface{}{}
-	for _, key := range sr.tags {
+	for _, key := range sr.GetKeys() {
 		if val, ok := sr.Get(key); ok {
 			vals = append(vals, val)
 		} else {
@@ -88,3 +261,124 @@ func (sr StructRecord) GetVals() []interface{} {
 	}
 	return vals
 }
+
+// Set implements the MutableRecord interface. Keys that aren't a field
+// and values that can't be converted to the field's type are ignored.
+// Use SetField to get the error instead.
+func (sr StructRecord) Set(key string, val interface{}) {
+	sr.SetField(key, val)
+}
+
+// SetField sets the field for the key to the value converting it to the
+// type of the field if needed. Nil sets the field to its zero value.
+func (sr StructRecord) SetField(key string, val interface{}) error {
+	sf, ok := sr.fields[key]
+	if !ok {
+		return errors.Wrap(ErrUnknownField, key)
+	}
+	if err := assign(field(sr.val, sf.index, true), val, sr.tagName); err != nil {
+		return errors.Wrap(err, key)
+	}
+	return nil
+}
+
+// Decode sets the fields of the struct ptr points to from the record. The
+// keys are matched to the fields by the tag like NewNestedStructRecord so
+// dotted keys set nested fields. Values are converted to the field types
+// and records are decoded into struct fields. Keys that don't match a
+// field are ignored.
+func Decode(r Record, ptr interface{}, tag string) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.Wrapf(ErrNotAStruct, "can't decode into %T", ptr)
+	}
+	sr, err := NewNestedStructRecord(ptr, tag)
+	if err != nil {
+		return err
+	}
+	for _, key := range r.GetKeys() {
+		if _, ok := sr.fields[key]; !ok {
+			continue
+		}
+		val, _ := r.Get(key)
+		if err := sr.SetField(key, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assign sets dst to v converting it as needed.
+func assign(dst reflect.Value, v interface{}, tag string) error {
+	if v == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	// let the field scan the value like a database driver would
+	if dst.CanAddr() && dst.Addr().Type().Implements(scannerType) {
+		return dst.Addr().Interface().(sql.Scanner).Scan(v)
+	}
+
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return assign(dst.Elem(), v, tag)
+	}
+
+	src := reflect.ValueOf(v)
+	if src.Type().AssignableTo(dst.Type()) {
+		dst.Set(src)
+		return nil
+	}
+	if b, ok := v.([]byte); ok {
+		v = string(b) // drivers often return text as []byte
+	}
+
+	var conv interface{}
+	var err error
+	switch dst.Kind() {
+	case reflect.String:
+		conv = String(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if i, err = toInt(v); err == nil && dst.OverflowInt(i) {
+			err = fmt.Errorf("%v overflows %s", v, dst.Type())
+		}
+		conv = i
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		if u, err = toUint(v); err == nil && dst.OverflowUint(u) {
+			err = fmt.Errorf("%v overflows %s", v, dst.Type())
+		}
+		conv = u
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = toFloat(v); err == nil && dst.OverflowFloat(f) {
+			err = fmt.Errorf("%v overflows %s", v, dst.Type())
+		}
+		conv = f
+	case reflect.Bool:
+		conv, err = toBool(v)
+	case reflect.Struct:
+		if dst.Type() == timeType {
+			conv, err = toTime(v, "")
+			break
+		}
+		if r, ok := v.(Record); ok {
+			return Decode(r, dst.Addr().Interface(), tag)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if conv != nil {
+		src = reflect.ValueOf(conv)
+	}
+	if src.Type().ConvertibleTo(dst.Type()) {
+		dst.Set(src.Convert(dst.Type()))
+		return nil
+	}
+	return fmt.Errorf("can't convert %T to %s", v, dst.Type())
+}
@@ -1,6 +1,7 @@
 package message_test
 
 import (
+	"math"
 	"reflect"
 	"testing"
 
@@ -88,3 +89,131 @@ func TestStructRecord(t *testing.T) {
 		}
 	})
 }
+
+type testBase struct {
+	ID      int    `db:"id"`
+	Created string `db:"created_at,omitempty"`
+}
+
+type testAddress struct {
+	Street string `db:"street"`
+	Zip    string `db:"zip"`
+}
+
+type testUser struct {
+	testBase
+	Name     string       `db:"name"`
+	Password string       `db:"-"`
+	secret   string       // unexported so skipped
+	Home     testAddress  `db:"home"`
+	Work     *testAddress `db:"work"`
+	Score    float64      // no tag so the field name is used
+}
+
+func TestStructRecord_fields(t *testing.T) {
+	u := &testUser{testBase: testBase{ID: 1}, Name: "ann", Password: "pw", secret: "s", Home: testAddress{Zip: "84101"}}
+
+	r, err := message.NewStructRecord(u, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantKeys := []string{"id", "name", "home", "work", "Score"}
+	if !reflect.DeepEqual(wantKeys, r.GetKeys()) {
+		t.Errorf("want %v got %v", wantKeys, r.GetKeys())
+	}
+	if v, ok := r.Get("created_at"); !ok || v != "" {
+		t.Errorf("expected to get the omitted field got %v %v", v, ok)
+	}
+
+	r.Set("created_at", []byte("today"))
+	r.Set("id", int64(2))
+	if u.ID != 2 || u.Created != "today" {
+		t.Errorf("expected the struct to be set got %+v", u)
+	}
+	wantKeys = []string{"id", "created_at", "name", "home", "work", "Score"}
+	if !reflect.DeepEqual(wantKeys, r.GetKeys()) {
+		t.Errorf("want %v got %v", wantKeys, r.GetKeys())
+	}
+	if err := r.SetField("Password", "x"); err == nil {
+		t.Error("expected an error for a skipped field")
+	}
+	if err := r.SetField("id", "one"); err == nil {
+		t.Error("expected an error for a bad value")
+	}
+}
+
+func TestStructRecord_nested(t *testing.T) {
+	u := testUser{Home: testAddress{Zip: "84101"}}
+
+	r, err := message.NewNestedStructRecord(u, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantKeys := []string{"id", "name", "home.street", "home.zip", "work.street", "work.zip", "Score"}
+	if !reflect.DeepEqual(wantKeys, r.GetKeys()) {
+		t.Errorf("want %v got %v", wantKeys, r.GetKeys())
+	}
+	if v, ok := r.Get("work.zip"); !ok || v != nil {
+		t.Errorf("expected nil through a nil pointer got %v %v", v, ok)
+	}
+
+	r.Set("work.zip", "84102")
+	got := r.In().(testUser)
+	if got.Work == nil || got.Work.Zip != "84102" || got.Home.Zip != "84101" {
+		t.Errorf("unexpected struct %+v", got)
+	}
+	if u.Work != nil {
+		t.Error("expected the struct passed by value to be left as is")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	work := message.NewRecord()
+	work.Set("zip", "84102")
+	r := message.NewRecord()
+	r.Set("id", int64(3))
+	r.Set("name", []byte("ann"))
+	r.Set("home.zip", "84101")
+	r.Set("work", work)
+	r.Set("Score", "1.5")
+	r.Set("unknown", true)
+
+	var u testUser
+	if err := message.Decode(r, &u, "db"); err != nil {
+		t.Fatal(err)
+	}
+	want := testUser{testBase: testBase{ID: 3}, Name: "ann", Home: testAddress{Zip: "84101"}, Work: &testAddress{Zip: "84102"}, Score: 1.5}
+	if !reflect.DeepEqual(want, u) {
+		t.Errorf("want %+v got %+v", want, u)
+	}
+
+	if err := message.Decode(r, u, "db"); err == nil {
+		t.Error("expected an error decoding into a non pointer")
+	}
+}
+
+func TestStructRecord_setErrors(t *testing.T) {
+	var n struct {
+		Small int8
+		Count uint
+		Big   uint64
+	}
+	r, err := message.NewStructRecord(&n, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Set ignores what it can't set instead of panicking
+	r.Set("nope", 1)
+	r.Set("Count", "one")
+
+	if err := r.SetField("Count", -1); err == nil {
+		t.Errorf("expected an error for a negative uint got %d", n.Count)
+	}
+	if err := r.SetField("Small", 300); err == nil {
+		t.Errorf("expected an error for an overflowing int8 got %d", n.Small)
+	}
+	if err := r.SetField("Big", uint64(math.MaxUint64)); err != nil || n.Big != math.MaxUint64 {
+		t.Errorf("expected the max uint64 got %d (%v)", n.Big, err)
+	}
+}
@@ -0,0 +1,123 @@
+package message
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UpsertStyle is the flavor of SQL an UpsertDelta is written in.
+type UpsertStyle int
+
+// Upsert styles
+const (
+	UpsertOnDuplicateKey UpsertStyle = iota // MySQL INSERT ... ON DUPLICATE KEY UPDATE
+	UpsertOnConflict                        // PostgreSQL and SQLite INSERT ... ON CONFLICT (...) DO UPDATE
+	UpsertMerge                             // MERGE INTO ... USING ... for SQL Server and others
+)
+
+// UpsertDelta is the delta type for inserting a record
+// or updating it if a row with the same ID already exists.
+type UpsertDelta struct {
+	IDRecord // IDRecord as the ID keys are what conflict
+	Table    string
+	Style    UpsertStyle
+	Dialect  Dialect // optional dialect of the SQL which writes the upsert instead of Style
+
+	// UpdateCols are the only columns updated when the row exists.
+	// All the non-ID columns are updated if it is empty.
+	UpdateCols []string
+
+	// DoNothing leaves an existing row as is.
+	DoNothing bool
+}
+
+// NewUpsertDelta is an upsert change record related to a table.
+func NewUpsertDelta(r IDRecord, table string, style UpsertStyle) *UpsertDelta {
+	return &UpsertDelta{
+		IDRecord: r,
+		Table:    table,
+		Style:    style,
+	}
+}
+
+// updateCols gets the columns to update when the row exists.
+func (d UpsertDelta) updateCols() []string {
+	if d.DoNothing {
+		return nil
+	}
+	if len(d.UpdateCols) > 0 {
+		return d.UpdateCols
+	}
+	return GetNonIDKeys(d)
+}
+
+// GetSQL implements the SQLGetter interface
+func (d UpsertDelta) GetSQL() string {
+	if d.Dialect != nil {
+		return d.Dialect.Upsert(d)
+	}
+	return d.StyleSQL(d.Style)
+}
+
+// StyleSQL writes the SQL of the upsert in the style with the placeholders
+// and quoting of the Dialect. Dialects use it to write their upserts.
+func (d UpsertDelta) StyleSQL(style UpsertStyle) string {
+	q := func(name string) string { return ident(d.Dialect, name) }
+	phs := placeholders{d: d.Dialect}
+	keys := d.GetKeys()
+	cols := strings.Join(idents(d.Dialect, keys), ",")
+	table := q(d.Table)
+
+	var sets []string
+	switch style {
+	case UpsertOnConflict:
+		for _, col := range d.updateCols() {
+			sets = append(sets, q(col)+"=excluded."+q(col))
+		}
+		insert := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`, table, cols, phs.list(len(keys)))
+		conflict := strings.Join(idents(d.Dialect, d.GetIDKeys()), ",")
+		if len(sets) == 0 {
+			return fmt.Sprintf(`%s ON CONFLICT (%s) DO NOTHING`, insert, conflict)
+		}
+		return fmt.Sprintf(`%s ON CONFLICT (%s) DO UPDATE SET %s`, insert, conflict, strings.Join(sets, ", "))
+
+	case UpsertMerge:
+		source := make([]string, len(keys))
+		vals := make([]string, len(keys))
+		for i, k := range keys {
+			source[i] = phs.next() + " AS " + q(k)
+			vals[i] = "source." + q(k)
+		}
+		on := make([]string, len(d.GetIDKeys()))
+		for i, k := range d.GetIDKeys() {
+			on[i] = "target." + q(k) + "=source." + q(k)
+		}
+		for _, col := range d.updateCols() {
+			sets = append(sets, q(col)+"=source."+q(col))
+		}
+
+		sql := fmt.Sprintf(`MERGE INTO %s AS target USING (SELECT %s) AS source ON %s`,
+			table, strings.Join(source, ", "), strings.Join(on, " AND "))
+		if len(sets) > 0 {
+			sql += " WHEN MATCHED THEN UPDATE SET " + strings.Join(sets, ", ")
+		}
+		return fmt.Sprintf(`%s WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s);`,
+			sql, cols, strings.Join(vals, ","))
+	}
+
+	// MySQL has no do nothing so the first ID key is set to itself
+	for _, col := range d.updateCols() {
+		sets = append(sets, q(col)+"=VALUES("+q(col)+")")
+	}
+	if len(sets) == 0 && len(d.GetIDKeys()) > 0 {
+		id := q(d.GetIDKeys()[0])
+		sets = append(sets, id+"="+id)
+	}
+	insert := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`, table, cols, phs.list(len(keys)))
+	return fmt.Sprintf(`%s ON DUPLICATE KEY UPDATE %s`, insert, strings.Join(sets, ", "))
+}
+
+// GetArgs implements the ArgGetter interface
+func (d UpsertDelta) GetArgs() []interface{} {
+	return d.GetVals()
+}
@@ -0,0 +1,48 @@
+package message_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+func TestUpsertDelta(t *testing.T) {
+	r := idRecord("id", 1, "name", "quux", "score", 5)
+
+	cases := []struct {
+		style      message.UpsertStyle
+		updateCols []string
+		doNothing  bool
+		want       string
+	}{
+		{message.UpsertOnDuplicateKey, nil, false,
+			"INSERT INTO foo (id,name,score) VALUES (?,?,?) ON DUPLICATE KEY UPDATE name=VALUES(name), score=VALUES(score)"},
+		{message.UpsertOnDuplicateKey, []string{"score"}, false,
+			"INSERT INTO foo (id,name,score) VALUES (?,?,?) ON DUPLICATE KEY UPDATE score=VALUES(score)"},
+		{message.UpsertOnDuplicateKey, nil, true,
+			"INSERT INTO foo (id,name,score) VALUES (?,?,?) ON DUPLICATE KEY UPDATE id=id"},
+		{message.UpsertOnConflict, nil, false,
+			"INSERT INTO foo (id,name,score) VALUES (?,?,?) ON CONFLICT (id) DO UPDATE SET name=excluded.name, score=excluded.score"},
+		{message.UpsertOnConflict, nil, true,
+			"INSERT INTO foo (id,name,score) VALUES (?,?,?) ON CONFLICT (id) DO NOTHING"},
+		{message.UpsertMerge, []string{"score"}, false,
+			"MERGE INTO foo AS target USING (SELECT ? AS id, ? AS name, ? AS score) AS source ON target.id=source.id " +
+				"WHEN MATCHED THEN UPDATE SET score=source.score " +
+				"WHEN NOT MATCHED THEN INSERT (id,name,score) VALUES (source.id,source.name,source.score);"},
+		{message.UpsertMerge, nil, true,
+			"MERGE INTO foo AS target USING (SELECT ? AS id, ? AS name, ? AS score) AS source ON target.id=source.id " +
+				"WHEN NOT MATCHED THEN INSERT (id,name,score) VALUES (source.id,source.name,source.score);"},
+	}
+	for _, c := range cases {
+		d := message.NewUpsertDelta(r, "foo", c.style)
+		d.UpdateCols = c.updateCols
+		d.DoNothing = c.doNothing
+		if d.GetSQL() != c.want {
+			t.Errorf("want '%s' got '%s'", c.want, d.GetSQL())
+		}
+		if want := []interface{}{1, "quux", 5}; !reflect.DeepEqual(want, d.GetArgs()) {
+			t.Errorf("want %v got %v", want, d.GetArgs())
+		}
+	}
+}
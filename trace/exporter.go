@@ -0,0 +1,82 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// Exporter is where finished spans are sent.
+type Exporter interface {
+	Export(Span) error
+}
+
+// MemoryExporter keeps the spans in memory. This is useful for tests.
+type MemoryExporter struct {
+	mx    sync.Mutex
+	spans []Span
+}
+
+// Export implements the Exporter interface
+func (e *MemoryExporter) Export(s Span) error {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	e.spans = append(e.spans, s)
+	return nil
+}
+
+// Spans returns a copy of the exported spans.
+func (e *MemoryExporter) Spans() []Span {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	return append([]Span{}, e.spans...)
+}
+
+// Trace returns the exported spans for a single trace.
+func (e *MemoryExporter) Trace(traceID string) []Span {
+	var spans []Span
+	for _, s := range e.Spans() {
+		if s.TraceID == traceID {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+// JSONExporter writes each span as a line of JSON.
+type JSONExporter struct {
+	mx  sync.Mutex
+	enc *json.Encoder
+	w   io.Writer
+}
+
+// NewJSONExporter creates a JSONExporter writing to w.
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{enc: json.NewEncoder(w), w: w}
+}
+
+// NewFileExporter creates a JSONExporter that appends to the file at path.
+// Close the exporter when done to close the file.
+func NewFileExporter(path string) (*JSONExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONExporter(f), nil
+}
+
+// Export implements the Exporter interface
+func (e *JSONExporter) Export(s Span) error {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	return e.enc.Encode(s)
+}
+
+// Close closes the underlying writer if it is an io.Closer.
+func (e *JSONExporter) Close() error {
+	if c, ok := e.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+// Message wraps a message with the context carrying its span.
+// It implements message.Inner so message.Get and message.String see
+// through it to the wrapped message.
+type Message struct {
+	M   interface{}
+	Ctx context.Context
+}
+
+// Begin starts a new trace for the message. The exporter in the ctx
+// (if any) is carried along for the spans recorded for the message.
+func Begin(ctx context.Context, msg interface{}) *Message {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// no span yet so the first span recorded is the root of the trace
+	return &Message{M: msg, Ctx: WithSpan(ctx, SpanContext{TraceID: newID(16)})}
+}
+
+// In implements the message.Inner interface
+func (m *Message) In() interface{} {
+	return m.M
+}
+
+// GetContext implements the message.ContextGetter interface
+func (m *Message) GetContext() context.Context {
+	return m.Ctx
+}
+
+// String implements the fmt.Stringer interface
+func (m *Message) String() string {
+	return message.String(m.M)
+}
+
+// Context gets the context carrying the span of a message. It looks at
+// message.ContextGetter messages, http requests and responses, and
+// digs into wrapped messages. The returned bool is false if no span was found.
+func Context(msg interface{}) (context.Context, bool) {
+	var ctx context.Context
+	switch v := msg.(type) {
+	case message.ContextGetter:
+		ctx = v.GetContext()
+	case *http.Request:
+		ctx = v.Context()
+	case *http.Response:
+		if v.Request != nil {
+			ctx = v.Request.Context()
+		}
+	case message.Requester:
+		if req := v.Request(); req != nil {
+			ctx = req.Context()
+		}
+	}
+
+	if _, ok := SpanFrom(ctx); ok {
+		return ctx, true
+	}
+
+	if inner, ok := msg.(message.Inner); ok {
+		return Context(inner.In())
+	}
+	return nil, false
+}
+
+// Carry wraps the to message with the span context of the from message
+// so a stage that creates a new message can keep it in the same trace.
+// If from isn't traced, to is returned as is.
+func Carry(from, to interface{}) interface{} {
+	if to == nil {
+		return nil
+	}
+	if _, traced := Context(to); traced {
+		return to
+	}
+	if ctx, ok := Context(from); ok {
+		return &Message{M: to, Ctx: ctx}
+	}
+	return to
+}
+
+// WithContext returns the message with its context replaced by ctx for
+// the message types that can carry one (*Message, *http.Request and
+// message.Query). Other messages are returned as is.
+func WithContext(msg interface{}, ctx context.Context) interface{} {
+	switch v := msg.(type) {
+	case *Message:
+		return &Message{M: v.M, Ctx: ctx}
+	case *http.Request:
+		return v.WithContext(ctx)
+	case message.Query:
+		v.Context = ctx
+		return v
+	case *message.Query:
+		q := *v
+		q.Context = ctx
+		return &q
+	}
+	return msg
+}
@@ -0,0 +1,140 @@
+// Package trace records spans for messages as they move through a pipeline.
+//
+// A message carries its span context in a context.Context (see Begin and
+// Context). When a pipeline is run with line's SetTracer, the runtime records
+// a span for each stage a traced message passes through. Stages can record
+// their own spans with Start using the context of the message.
+//
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// SpanContext identifies a span within a trace.
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+}
+
+// Span is a single timed operation in a trace.
+type Span struct {
+	TraceID  string                 `json:"trace_id"`
+	SpanID   string                 `json:"span_id"`
+	ParentID string                 `json:"parent_id,omitempty"`
+	Name     string                 `json:"name"`
+	Start    time.Time              `json:"start"`
+	End      time.Time              `json:"end"`
+	Duration time.Duration          `json:"duration"`
+	Err      string                 `json:"error,omitempty"`
+	Attrs    map[string]interface{} `json:"attrs,omitempty"`
+}
+
+type spanKey struct{}
+type exporterKey struct{}
+
+// WithSpan returns a copy of the ctx carrying the span context.
+func WithSpan(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanKey{}, sc)
+}
+
+// SpanFrom gets the span context from the ctx.
+// The returned bool indicates if there was one.
+func SpanFrom(ctx context.Context) (SpanContext, bool) {
+	if ctx == nil {
+		return SpanContext{}, false
+	}
+	sc, ok := ctx.Value(spanKey{}).(SpanContext)
+	return sc, ok
+}
+
+// WithExporter returns a copy of the ctx carrying the exporter
+// that spans started from it are sent to.
+func WithExporter(ctx context.Context, e Exporter) context.Context {
+	return context.WithValue(ctx, exporterKey{}, e)
+}
+
+// ExporterFrom gets the exporter from the ctx or nil if there isn't one.
+func ExporterFrom(ctx context.Context) Exporter {
+	if ctx == nil {
+		return nil
+	}
+	e, _ := ctx.Value(exporterKey{}).(Exporter)
+	return e
+}
+
+// ActiveSpan is a span that has been started but hasn't ended yet.
+type ActiveSpan struct {
+	mx       sync.Mutex
+	span     Span
+	exporter Exporter
+	ended    bool
+}
+
+// Start starts a new span as a child of the span in the ctx, or as the root
+// of a new trace if the ctx doesn't have one. The returned context carries
+// the new span. The span is sent to the exporter in the ctx when it ends.
+func Start(ctx context.Context, name string) (context.Context, *ActiveSpan) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s := Span{SpanID: newID(8), Name: name, Start: time.Now()}
+	if parent, ok := SpanFrom(ctx); ok && parent.TraceID != "" {
+		s.TraceID = parent.TraceID
+		s.ParentID = parent.SpanID
+	} else {
+		s.TraceID = newID(16)
+	}
+
+	ctx = WithSpan(ctx, SpanContext{TraceID: s.TraceID, SpanID: s.SpanID})
+	return ctx, &ActiveSpan{span: s, exporter: ExporterFrom(ctx)}
+}
+
+// Context gets the span context of the span.
+func (s *ActiveSpan) Context() SpanContext {
+	return SpanContext{TraceID: s.span.TraceID, SpanID: s.span.SpanID}
+}
+
+// SetAttr sets an attribute on the span.
+func (s *ActiveSpan) SetAttr(key string, val interface{}) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.span.Attrs == nil {
+		s.span.Attrs = map[string]interface{}{}
+	}
+	s.span.Attrs[key] = val
+}
+
+// End ends the span with the error of the operation (if any) and sends
+// it to the exporter. Only the first call to End does anything.
+func (s *ActiveSpan) End(err error) error {
+	s.mx.Lock()
+	if s.ended {
+		s.mx.Unlock()
+		return nil
+	}
+	s.ended = true
+	s.span.End = time.Now()
+	s.span.Duration = s.span.End.Sub(s.span.Start)
+	if err != nil {
+		s.span.Err = err.Error()
+	}
+	span := s.span
+	s.mx.Unlock()
+
+	if s.exporter == nil {
+		return nil
+	}
+	return s.exporter.Export(span)
+}
+
+// newID makes a random hex id n bytes long.
+func newID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,91 @@
+package trace_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/MasteryConnect/pipe/message"
+	"github.com/MasteryConnect/pipe/trace"
+)
+
+func TestStart(t *testing.T) {
+	mem := &trace.MemoryExporter{}
+	ctx := trace.WithExporter(context.Background(), mem)
+
+	ctx, parent := trace.Start(ctx, "parent")
+	_, child := trace.Start(ctx, "child")
+	child.SetAttr("foo", "bar")
+	child.End(errors.New("child error"))
+	child.End(nil) // only the first End counts
+	parent.End(nil)
+
+	spans := mem.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans got %d", len(spans))
+	}
+	c, p := spans[0], spans[1]
+	if c.TraceID != p.TraceID {
+		t.Errorf("want the same trace got %s and %s", c.TraceID, p.TraceID)
+	}
+	if c.ParentID != p.SpanID || p.ParentID != "" {
+		t.Errorf("want child of %s got %s", p.SpanID, c.ParentID)
+	}
+	if c.Err != "child error" || c.Attrs["foo"] != "bar" {
+		t.Errorf("unexpected child span %+v", c)
+	}
+	if len(mem.Trace(p.TraceID)) != 2 {
+		t.Errorf("want 2 spans in the trace got %d", len(mem.Trace(p.TraceID)))
+	}
+}
+
+func TestCarry(t *testing.T) {
+	traced := trace.Begin(context.Background(), "foo")
+
+	if message.String(traced) != "foo" {
+		t.Errorf("want foo got %s", message.String(traced))
+	}
+
+	carried := trace.Carry(traced, "bar")
+	ctx, ok := trace.Context(carried)
+	if !ok {
+		t.Fatal("want the carried message to be traced")
+	}
+	want, _ := trace.SpanFrom(traced.Ctx)
+	got, _ := trace.SpanFrom(ctx)
+	if got != want {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+
+	if trace.Carry("foo", "bar") != "bar" {
+		t.Error("want an untraced message left as is")
+	}
+}
+
+func TestJSONExporter(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := trace.WithExporter(context.Background(), trace.NewJSONExporter(&buf))
+
+	for i := 0; i < 2; i++ {
+		_, span := trace.Start(ctx, "foo")
+		span.End(nil)
+	}
+
+	dec := json.NewDecoder(&buf)
+	cnt := 0
+	for dec.More() {
+		var s trace.Span
+		if err := dec.Decode(&s); err != nil {
+			t.Fatal(err)
+		}
+		if s.Name != "foo" {
+			t.Errorf("want foo got %s", s.Name)
+		}
+		cnt++
+	}
+	if cnt != 2 {
+		t.Errorf("want 2 lines got %d", cnt)
+	}
+}
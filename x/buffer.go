@@ -1,5 +1,7 @@
 package x
 
+import "github.com/MasteryConnect/pipe/line"
+
 // Buffer will create a buffer of Size to help "drain" a previous step.
 type Buffer struct {
 	N int
@@ -20,3 +22,8 @@ func (b Buffer) T(in <-chan interface{}, out chan<- interface{}, errs chan<- err
 		out <- msg
 	}
 }
+
+// Describe implements the line.Describer interface
+func (b Buffer) Describe() line.Node {
+	return line.Node{Name: "x.Buffer", Concurrency: 1, Buffer: b.N}
+}
@@ -0,0 +1,35 @@
+package x_test
+
+import (
+	"fmt"
+
+	l "github.com/MasteryConnect/pipe/line"
+	"github.com/MasteryConnect/pipe/x"
+)
+
+func Example_describe() {
+	shards, _ := x.NewShardMany(4, l.Noop, func(msg interface{}) []byte { return nil })
+
+	p := l.New().(l.TransformerAdder).
+		AddTransformer(
+			x.Buffer{N: 10},
+			x.Tees{l.Noop, x.Cap},
+			&x.If{Tfunc: l.Noop, Else: x.Cap},
+			shards,
+		)
+
+	fmt.Print(l.Describe(p).Text())
+	// Output:
+	// line.Line (pipeline)
+	//   line.Stdin (producer)
+	//   x.Buffer, buffer 10 (transformer)
+	//   x.Tee (transformer)
+	//     line.Noop (transformer)
+	//     x.Cap (transformer)
+	//   x.If (transformer)
+	//     line.Noop (transformer)
+	//     x.Cap (transformer)
+	//   x.ShardMany, concurrency 4 (transformer)
+	//     line.Noop (transformer)
+	//   line.Consumer (consumer)
+}
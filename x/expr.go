@@ -0,0 +1,610 @@
+package x
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+// ErrExpr is the error for an expression that can't be parsed or evaluated.
+var ErrExpr = errors.New("expression")
+
+// expr is a compiled expression evaluated against a record.
+type expr func(r message.Record) (interface{}, error)
+
+// exprFunc is a func that can be called in an expression
+// with at least min and at most max (-1 for any number of) args.
+type exprFunc struct {
+	min, max int
+	fn       func(args []interface{}) (interface{}, error)
+}
+
+// exprFuncs are the funcs that can be called in an expression.
+var exprFuncs = map[string]exprFunc{
+	"upper": {1, 1, func(args []interface{}) (interface{}, error) {
+		return strings.ToUpper(exprString(args[0])), nil
+	}},
+	"lower": {1, 1, func(args []interface{}) (interface{}, error) {
+		return strings.ToLower(exprString(args[0])), nil
+	}},
+	"trim": {1, 1, func(args []interface{}) (interface{}, error) {
+		return strings.TrimSpace(exprString(args[0])), nil
+	}},
+	"len": {1, 1, func(args []interface{}) (interface{}, error) {
+		rv := reflect.ValueOf(args[0])
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return int64(rv.Len()), nil
+		}
+		return int64(len(exprString(args[0]))), nil
+	}},
+	"concat": {0, -1, func(args []interface{}) (interface{}, error) {
+		var b strings.Builder
+		for _, a := range args {
+			b.WriteString(exprString(a))
+		}
+		return b.String(), nil
+	}},
+	"coalesce": {0, -1, func(args []interface{}) (interface{}, error) {
+		for _, a := range args {
+			if a != nil {
+				return a, nil
+			}
+		}
+		return nil, nil
+	}},
+	"if": {3, 3, func(args []interface{}) (interface{}, error) {
+		if truthy(args[0]) {
+			return args[1], nil
+		}
+		return args[2], nil
+	}},
+}
+
+// compileExpr parses a small expression like `price * qty` or
+// `concat(upper(first), " ", last)`. Fields of the record are referenced
+// by name or path (see message.GetPath) and missing fields are null.
+// Numbers with a decimal point and message.Decimal values are exact
+// decimals unless mixed with a float.
+// It supports numbers, 'strings' or "strings", true, false, null,
+// + - * / %, == != < <= > >=, && || !, parentheses and the funcs
+// upper, lower, trim, len, concat, coalesce and if(cond, then, else).
+func compileExpr(src string) (expr, error) {
+	toks, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, errors.Wrapf(ErrExpr, "unexpected %q in %q", p.toks[p.pos].text, src)
+	}
+	return e, nil
+}
+
+type tokKind int
+
+const (
+	tokNum tokKind = iota
+	tokStr
+	tokIdent
+	tokOp
+)
+
+type exprTok struct {
+	kind tokKind
+	text string
+	val  interface{} // the value of number and string literals
+}
+
+func lexExpr(src string) ([]exprTok, error) {
+	var toks []exprTok
+	rs := []rune(src)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			text := string(rs[i:j])
+			var val interface{}
+			var err error
+			if strings.Contains(text, ".") {
+				if _, ok := new(big.Rat).SetString(text); ok {
+					val = message.Decimal(text)
+				} else {
+					err = ErrExpr
+				}
+			} else {
+				val, err = strconv.ParseInt(text, 10, 64)
+			}
+			if err != nil {
+				return nil, errors.Wrapf(ErrExpr, "bad number %q", text)
+			}
+			toks = append(toks, exprTok{kind: tokNum, text: text, val: val})
+			i = j
+		case r == '"' || r == '\'':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(rs) && rs[j] != r; j++ {
+				if rs[j] == '\\' && j+1 < len(rs) {
+					j++
+				}
+				b.WriteRune(rs[j])
+			}
+			if j >= len(rs) {
+				return nil, errors.Wrapf(ErrExpr, "unterminated string in %q", src)
+			}
+			toks = append(toks, exprTok{kind: tokStr, text: string(rs[i : j+1]), val: b.String()})
+			i = j + 1
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || strings.ContainsRune("_.[]", rs[j])) {
+				j++
+			}
+			toks = append(toks, exprTok{kind: tokIdent, text: string(rs[i:j])})
+			i = j
+		default:
+			op := string(r)
+			if i+1 < len(rs) {
+				switch two := string(rs[i : i+2]); two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					op = two
+				}
+			}
+			if !strings.Contains("+-*/%<>!(),", op) && len(op) == 1 {
+				return nil, errors.Wrapf(ErrExpr, "unexpected %q in %q", op, src)
+			}
+			toks = append(toks, exprTok{kind: tokOp, text: op})
+			i += len(op)
+		}
+	}
+	return toks, nil
+}
+
+type exprParser struct {
+	toks []exprTok
+	pos  int
+}
+
+// accept moves past the next token if it is one of the ops.
+func (p *exprParser) accept(ops ...string) (string, bool) {
+	if p.pos >= len(p.toks) || p.toks[p.pos].kind != tokOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if p.toks[p.pos].text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// binary parses a left to right chain of the ops with next parsing each side.
+func (p *exprParser) binary(next func() (expr, error), ops ...string) (expr, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr(op, left, right)
+	}
+}
+
+func (p *exprParser) parseOr() (expr, error) {
+	return p.binary(p.parseAnd, "||")
+}
+
+func (p *exprParser) parseAnd() (expr, error) {
+	return p.binary(p.parseCmp, "&&")
+}
+
+func (p *exprParser) parseCmp() (expr, error) {
+	return p.binary(p.parseAdd, "==", "!=", "<=", ">=", "<", ">")
+}
+
+func (p *exprParser) parseAdd() (expr, error) {
+	return p.binary(p.parseMul, "+", "-")
+}
+
+func (p *exprParser) parseMul() (expr, error) {
+	return p.binary(p.parseUnary, "*", "/", "%")
+}
+
+func (p *exprParser) parseUnary() (expr, error) {
+	if op, ok := p.accept("!", "-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "!" {
+			return func(r message.Record) (interface{}, error) {
+				v, err := operand(r)
+				return !truthy(v), err
+			}, nil
+		}
+		return binaryExpr("-", constExpr(int64(0)), operand), nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (expr, error) {
+	if p.pos >= len(p.toks) {
+		return nil, errors.Wrap(ErrExpr, "unexpected end")
+	}
+	tok := p.toks[p.pos]
+	p.pos++
+
+	switch tok.kind {
+	case tokNum, tokStr:
+		return constExpr(tok.val), nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return constExpr(true), nil
+		case "false":
+			return constExpr(false), nil
+		case "null", "nil":
+			return constExpr(nil), nil
+		}
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(tok.text)
+		}
+		path := tok.text
+		return func(r message.Record) (interface{}, error) {
+			v, _ := message.GetPath(r, path)
+			return v, nil
+		}, nil
+	}
+
+	if tok.text == "(" {
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, errors.Wrap(ErrExpr, "missing )")
+		}
+		return e, nil
+	}
+	return nil, errors.Wrapf(ErrExpr, "unexpected %q", tok.text)
+}
+
+func (p *exprParser) parseCall(name string) (expr, error) {
+	f, ok := exprFuncs[name]
+	if !ok {
+		return nil, errors.Wrapf(ErrExpr, "unknown func %s", name)
+	}
+
+	var args []expr
+	if _, ok := p.accept(")"); !ok {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.accept(","); ok {
+				continue
+			}
+			if _, ok := p.accept(")"); !ok {
+				return nil, errors.Wrapf(ErrExpr, "missing ) for %s", name)
+			}
+			break
+		}
+	}
+	if len(args) < f.min || (f.max >= 0 && len(args) > f.max) {
+		return nil, errors.Wrapf(ErrExpr, "%s takes %s got %d", name, f.arity(), len(args))
+	}
+
+	return func(r message.Record) (interface{}, error) {
+		vals := make([]interface{}, len(args))
+		for i, arg := range args {
+			v, err := arg(r)
+			if err != nil {
+				return nil, err
+			}
+			vals[i] = v
+		}
+		return f.fn(vals)
+	}, nil
+}
+
+// arity describes the number of args the func takes.
+func (f exprFunc) arity() string {
+	switch {
+	case f.max < 0:
+		return fmt.Sprintf("at least %d args", f.min)
+	case f.min == f.max && f.min == 1:
+		return "1 arg"
+	case f.min == f.max:
+		return fmt.Sprintf("%d args", f.min)
+	}
+	return fmt.Sprintf("%d to %d args", f.min, f.max)
+}
+
+func constExpr(v interface{}) expr {
+	return func(message.Record) (interface{}, error) { return v, nil }
+}
+
+func binaryExpr(op string, left, right expr) expr {
+	return func(r message.Record) (interface{}, error) {
+		a, err := left(r)
+		if err != nil {
+			return nil, err
+		}
+
+		// short circuit the logic ops
+		switch op {
+		case "&&":
+			if !truthy(a) {
+				return false, nil
+			}
+			b, err := right(r)
+			return truthy(b), err
+		case "||":
+			if truthy(a) {
+				return true, nil
+			}
+			b, err := right(r)
+			return truthy(b), err
+		}
+
+		b, err := right(r)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "==":
+			return exprEqual(a, b), nil
+		case "!=":
+			return !exprEqual(a, b), nil
+		case "<", "<=", ">", ">=":
+			return compare(op, a, b)
+		}
+		return arith(op, a, b)
+	}
+}
+
+// number converts the value to an int64, a float64 or
+// a *big.Rat for a message.Decimal.
+func number(v interface{}) (interface{}, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint, uint8, uint16, uint32, uint64:
+		u, _ := strconv.ParseUint(fmt.Sprint(n), 10, 64)
+		return int64(u), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case message.Decimal:
+		return n.Rat()
+	}
+	return nil, false
+}
+
+func toFloat64(n interface{}) float64 {
+	switch v := n.(type) {
+	case int64:
+		return float64(v)
+	case *big.Rat:
+		f, _ := v.Float64()
+		return f
+	}
+	return n.(float64)
+}
+
+func toRat(n interface{}) *big.Rat {
+	if i, ok := n.(int64); ok {
+		return new(big.Rat).SetInt64(i)
+	}
+	return n.(*big.Rat)
+}
+
+// exact is true if neither number is a float so they can be done as decimals.
+func exact(a, b interface{}) bool {
+	_, fa := a.(float64)
+	_, fb := b.(float64)
+	return !fa && !fb
+}
+
+// exprDecimalPlaces is how many places a decimal division that doesn't
+// end is rounded to.
+const exprDecimalPlaces = 16
+
+// ratDecimal converts the result of decimal arithmetic back to a Decimal.
+func ratDecimal(r *big.Rat) message.Decimal {
+	// the places needed for an exact result if the denominator is 2^x * 5^y
+	d := new(big.Int).Set(r.Denom())
+	two, five := 0, 0
+	for m := new(big.Int); d.Cmp(big.NewInt(1)) != 0; {
+		if m.Mod(d, big.NewInt(2)).Sign() == 0 {
+			d.Quo(d, big.NewInt(2))
+			two++
+		} else if m.Mod(d, big.NewInt(5)).Sign() == 0 {
+			d.Quo(d, big.NewInt(5))
+			five++
+		} else {
+			return message.Decimal(r.FloatString(exprDecimalPlaces))
+		}
+	}
+	if five > two {
+		two = five
+	}
+	return message.Decimal(r.FloatString(two))
+}
+
+func arith(op string, a, b interface{}) (interface{}, error) {
+	_, aStr := a.(string)
+	_, bStr := b.(string)
+	if op == "+" && (aStr || bStr) {
+		return exprString(a) + exprString(b), nil
+	}
+
+	na, okA := number(a)
+	nb, okB := number(b)
+	if !okA || !okB {
+		return nil, errors.Wrapf(ErrExpr, "can't %v %s %v", a, op, b)
+	}
+
+	ia, intA := na.(int64)
+	ib, intB := nb.(int64)
+	if intA && intB {
+		switch op {
+		case "+":
+			return ia + ib, nil
+		case "-":
+			return ia - ib, nil
+		case "*":
+			return ia * ib, nil
+		case "/", "%":
+			if ib == 0 {
+				return nil, errors.Wrap(ErrExpr, "division by zero")
+			}
+			if op == "/" {
+				return ia / ib, nil
+			}
+			return ia % ib, nil
+		}
+	}
+
+	if exact(na, nb) {
+		ra, rb := toRat(na), toRat(nb)
+		z := new(big.Rat)
+		switch op {
+		case "+":
+			return ratDecimal(z.Add(ra, rb)), nil
+		case "-":
+			return ratDecimal(z.Sub(ra, rb)), nil
+		case "*":
+			return ratDecimal(z.Mul(ra, rb)), nil
+		case "/":
+			if rb.Sign() == 0 {
+				return nil, errors.Wrap(ErrExpr, "division by zero")
+			}
+			return ratDecimal(z.Quo(ra, rb)), nil
+		}
+		return nil, errors.Wrapf(ErrExpr, "can't %v %s %v", a, op, b)
+	}
+
+	fa, fb := toFloat64(na), toFloat64(nb)
+	switch op {
+	case "+":
+		return fa + fb, nil
+	case "-":
+		return fa - fb, nil
+	case "*":
+		return fa * fb, nil
+	case "/":
+		if fb == 0 {
+			return nil, errors.Wrap(ErrExpr, "division by zero")
+		}
+		return fa / fb, nil
+	}
+	return nil, errors.Wrapf(ErrExpr, "can't %v %s %v", a, op, b)
+}
+
+// cmpNumbers compares the numbers exactly unless one is a float.
+func cmpNumbers(a, b interface{}) int {
+	if exact(a, b) {
+		return toRat(a).Cmp(toRat(b))
+	}
+	fa, fb := toFloat64(a), toFloat64(b)
+	switch {
+	case fa < fb:
+		return -1
+	case fa > fb:
+		return 1
+	}
+	return 0
+}
+
+func compare(op string, a, b interface{}) (interface{}, error) {
+	var cmp int
+	na, okA := number(a)
+	nb, okB := number(b)
+	sa, strA := a.(string)
+	sb, strB := b.(string)
+	switch {
+	case okA && okB:
+		cmp = cmpNumbers(na, nb)
+	case strA && strB:
+		cmp = strings.Compare(sa, sb)
+	default:
+		return nil, errors.Wrapf(ErrExpr, "can't compare %v %s %v", a, op, b)
+	}
+
+	switch op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	}
+	return cmp >= 0, nil
+}
+
+func exprEqual(a, b interface{}) bool {
+	if na, ok := number(a); ok {
+		if nb, ok := number(b); ok {
+			return cmpNumbers(na, nb) == 0
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func truthy(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case string:
+		return val != ""
+	}
+	if n, ok := number(v); ok {
+		return cmpNumbers(n, int64(0)) != 0
+	}
+	return true
+}
+
+func exprString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return message.String(v)
+}
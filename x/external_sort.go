@@ -0,0 +1,368 @@
+package x
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+// ExternalSort sorts all of the messages exactly even when there are too many
+// to fit in memory. Runs of messages are sorted in memory and once a run is
+// full it is spilled to a temp file with the Codec. When the input is done the
+// runs are merged in order. Messages that compare the same stay in the order
+// they came in. Spilled messages need to be types the Codec knows (see
+// message.Register) and come back out as copies.
+type ExternalSort struct {
+	Compare  func(x, y interface{}) bool // true if x <= y (see message.CompareBy for records)
+	MaxCount int                         // the most messages in a run
+	MaxBytes int                         // the most bytes of messages in a run as roughly sized in memory
+	Codec    message.Codec               // the encoding of the temp files (message.CompactCodec by default)
+	Dir      string                      // the directory of the temp files (os.TempDir by default)
+	FanIn    int                         // the most runs merged at once (64 by default)
+}
+
+// the defaults for an ExternalSort
+const (
+	defaultSortRunCount = 100000 // used if neither MaxCount or MaxBytes is set
+	defaultSortFanIn    = 64
+)
+
+// T is the Tfunc for ExternalSort.
+func (s ExternalSort) T(in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
+	s.TContext(context.Background(), in, out, errs)
+}
+
+// TContext is the TfuncContext for ExternalSort. The sort stops when the
+// context is done and the temp files are removed whatever happens.
+func (s ExternalSort) TContext(ctx context.Context, in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
+	if s.Compare == nil {
+		errs <- errors.New("external sort: no Compare func")
+	} else {
+		es := &externalSort{ExternalSort: s}
+		err := es.run(ctx, in, out)
+		es.cleanup()
+		if err != nil && ctx.Err() == nil {
+			errs <- errors.Wrap(err, "external sort")
+		}
+	}
+
+	for range in {
+		// let the upstream finish if the sort stopped early
+	}
+}
+
+// externalSort is the state of a single sort.
+type externalSort struct {
+	ExternalSort
+	dir   string   // the temp dir made for the runs
+	runs  []string // the paths of the spilled runs in the order they were made
+	files []*os.File
+}
+
+func (es *externalSort) run(ctx context.Context, in <-chan interface{}, out chan<- interface{}) error {
+	var run []interface{}
+	size := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case m, ok := <-in:
+			if !ok {
+				return es.finish(ctx, run, out)
+			}
+			run = append(run, m)
+			size += approxSize(m)
+			if es.full(len(run), size) {
+				if err := es.spill(run); err != nil {
+					return err
+				}
+				run, size = nil, 0
+			}
+		}
+	}
+}
+
+func (es *externalSort) full(cnt, size int) bool {
+	if es.MaxCount <= 0 && es.MaxBytes <= 0 {
+		return cnt >= defaultSortRunCount
+	}
+	return (es.MaxCount > 0 && cnt >= es.MaxCount) || (es.MaxBytes > 0 && size >= es.MaxBytes)
+}
+
+// less is true if x sorts before y.
+func (es *externalSort) less(x, y interface{}) bool {
+	return !es.Compare(y, x)
+}
+
+// finish merges the spilled runs with the last run which stays in memory.
+func (es *externalSort) finish(ctx context.Context, run []interface{}, out chan<- interface{}) error {
+	sort.SliceStable(run, func(i, j int) bool { return es.less(run[i], run[j]) })
+
+	fanIn := es.FanIn
+	if fanIn < 2 {
+		fanIn = defaultSortFanIn
+	}
+	// merge the oldest runs into bigger ones until the rest can be merged at once
+	for len(es.runs)+1 > fanIn {
+		if err := es.mergeRuns(ctx, fanIn); err != nil {
+			return err
+		}
+	}
+
+	srcs, err := es.open(es.runs)
+	if err != nil {
+		return err
+	}
+	srcs = append(srcs, &sliceRun{msgs: run})
+	return es.merge(ctx, srcs, func(m interface{}) error {
+		select {
+		case out <- m:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// spill sorts the run and writes it to a new temp file.
+func (es *externalSort) spill(run []interface{}) error {
+	sort.SliceStable(run, func(i, j int) bool { return es.less(run[i], run[j]) })
+	return es.write(func(emit func(interface{}) error) error {
+		for _, m := range run {
+			if err := emit(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// mergeRuns merges the first n runs into one run which takes their place.
+func (es *externalSort) mergeRuns(ctx context.Context, n int) error {
+	paths := es.runs[:n]
+	srcs, err := es.open(paths)
+	if err != nil {
+		return err
+	}
+
+	rest := append([]string{}, es.runs[n:]...)
+	es.runs = nil
+	err = es.write(func(emit func(interface{}) error) error {
+		return es.merge(ctx, srcs, emit)
+	})
+	if err != nil {
+		return err
+	}
+	es.runs = append(es.runs, rest...)
+
+	es.closeFiles()
+	for _, p := range paths {
+		os.Remove(p)
+	}
+	return nil
+}
+
+// write writes the messages fill emits to a new run file.
+func (es *externalSort) write(fill func(emit func(interface{}) error) error) error {
+	if es.dir == "" {
+		dir, err := ioutil.TempDir(es.Dir, "externalsort-")
+		if err != nil {
+			return err
+		}
+		es.dir = dir
+	}
+
+	f, err := ioutil.TempFile(es.dir, "run-")
+	if err != nil {
+		return err
+	}
+	es.runs = append(es.runs, f.Name())
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := es.codec().NewEncoder(w)
+	if err := fill(enc.Encode); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (es *externalSort) codec() message.Codec {
+	if es.Codec == nil {
+		return message.CompactCodec
+	}
+	return es.Codec
+}
+
+// open opens the run files to be read in order.
+func (es *externalSort) open(paths []string) ([]sortRun, error) {
+	srcs := make([]sortRun, 0, len(paths)+1)
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		es.files = append(es.files, f)
+		srcs = append(srcs, &fileRun{dec: es.codec().NewDecoder(bufio.NewReader(f))})
+	}
+	return srcs, nil
+}
+
+func (es *externalSort) closeFiles() {
+	for _, f := range es.files {
+		f.Close()
+	}
+	es.files = nil
+}
+
+// cleanup closes and removes all of the temp files.
+func (es *externalSort) cleanup() {
+	es.closeFiles()
+	if es.dir != "" {
+		os.RemoveAll(es.dir)
+	}
+}
+
+// merge emits the messages of the sorted runs in order. Messages that
+// compare the same are emitted in the order of their runs so it is stable.
+func (es *externalSort) merge(ctx context.Context, srcs []sortRun, emit func(interface{}) error) error {
+	h := &sortHeap{less: es.less}
+	for i, src := range srcs {
+		m, ok, err := src.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			h.items = append(h.items, sortItem{msg: m, run: i})
+		}
+	}
+	heap.Init(h)
+
+	for h.Len() > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		top := h.items[0]
+		if err := emit(top.msg); err != nil {
+			return err
+		}
+
+		m, ok, err := srcs[top.run].next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			h.items[0].msg = m
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return nil
+}
+
+// sortRun is a sorted run of messages to merge.
+type sortRun interface {
+	next() (interface{}, bool, error)
+}
+
+type sliceRun struct {
+	msgs []interface{}
+	i    int
+}
+
+func (r *sliceRun) next() (interface{}, bool, error) {
+	if r.i >= len(r.msgs) {
+		return nil, false, nil
+	}
+	m := r.msgs[r.i]
+	r.msgs[r.i] = nil // let it be collected once sent
+	r.i++
+	return m, true, nil
+}
+
+type fileRun struct {
+	dec message.Decoder
+}
+
+func (r *fileRun) next() (interface{}, bool, error) {
+	m, err := r.dec.Decode()
+	if err == io.EOF {
+		return nil, false, nil
+	}
+	return m, err == nil, err
+}
+
+type sortItem struct {
+	msg interface{}
+	run int
+}
+
+// sortHeap implements heap.Interface with ties going to the earlier run.
+type sortHeap struct {
+	items []sortItem
+	less  func(x, y interface{}) bool
+}
+
+func (h *sortHeap) Len() int      { return len(h.items) }
+func (h *sortHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *sortHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if h.less(a.msg, b.msg) {
+		return true
+	}
+	if h.less(b.msg, a.msg) {
+		return false
+	}
+	return a.run < b.run
+}
+func (h *sortHeap) Push(x interface{}) { h.items = append(h.items, x.(sortItem)) }
+func (h *sortHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// approxSize roughly sizes a message in memory for MaxBytes.
+func approxSize(v interface{}) int {
+	switch val := v.(type) {
+	case string:
+		return 16 + len(val)
+	case []byte:
+		return 24 + len(val)
+	case message.Record:
+		size := 48
+		for _, k := range val.GetKeys() {
+			kv, _ := val.Get(k)
+			size += 16 + len(k) + approxSize(kv)
+		}
+		return size
+	case []interface{}:
+		return approxSlice(val)
+	case message.Batch:
+		return approxSlice(val)
+	case message.Inner:
+		return 16 + approxSize(val.In())
+	}
+	return 16
+}
+
+func approxSlice(s []interface{}) int {
+	size := 24
+	for _, v := range s {
+		size += approxSize(v)
+	}
+	return size
+}
@@ -0,0 +1,144 @@
+package x_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/MasteryConnect/pipe/line"
+	"github.com/MasteryConnect/pipe/message"
+	"github.com/MasteryConnect/pipe/x"
+)
+
+func ExampleExternalSort() {
+	line.New().SetP(func(out chan<- interface{}, errs chan<- error) {
+		for _, name := range []string{"file10", "file2", "file1", "file3"} {
+			r := message.NewRecord()
+			r.Set("name", name)
+			out <- r
+		}
+	}).Add(
+		x.ExternalSort{
+			Compare:  message.CompareBy(message.SortKey{Key: "name"}),
+			MaxCount: 2, // spill every 2 messages to a temp file
+		}.T,
+		line.Stdout,
+	).Run()
+
+	// Output:
+	// {"name":"file1"}
+	// {"name":"file2"}
+	// {"name":"file3"}
+	// {"name":"file10"}
+}
+
+func TestExternalSort_stable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "external_sort_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const n = 1000
+	for _, codec := range []message.Codec{message.CompactCodec, message.JSONCodec, message.GobCodec} {
+		in := make(chan interface{})
+		out := make(chan interface{}, n)
+		errs := make(chan error, 1)
+		go func() {
+			defer close(in)
+			for i := 0; i < n; i++ {
+				r := message.NewRecord()
+				r.Set("key", (i*7)%10)
+				r.Set("seq", i)
+				in <- r
+			}
+		}()
+
+		x.ExternalSort{
+			Compare:  message.CompareBy(message.SortKey{Key: "key"}),
+			MaxBytes: 1000, // runs of about 10 records
+			Codec:    codec,
+			Dir:      dir,
+			FanIn:    4, // so the runs are merged over a few passes
+		}.T(in, out, errs)
+		close(out)
+
+		if len(errs) > 0 {
+			t.Fatalf("%s: %v", codec.Name(), <-errs)
+		}
+		cnt, prevKey, prevSeq := 0, -1, -1
+		for m := range out {
+			r := m.(message.Record)
+			k, _ := r.Get("key")
+			s, _ := r.Get("seq")
+			c, seq := message.Compare(k, prevKey), s.(int)
+			if c < 0 || c == 0 && seq < prevSeq {
+				t.Fatalf("%s: %v is out of order after key %d seq %d", codec.Name(), r, prevKey, prevSeq)
+			}
+			prevKey, prevSeq = k.(int), seq
+			cnt++
+		}
+		if cnt != n {
+			t.Errorf("%s: expected %d messages got %d", codec.Name(), n, cnt)
+		}
+	}
+
+	if files, _ := ioutil.ReadDir(dir); len(files) > 0 {
+		t.Errorf("expected the temp files to be removed got %d", len(files))
+	}
+}
+
+func TestExternalSort_cancel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "external_sort_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan interface{})
+	out := make(chan interface{})
+	errs := make(chan error, 1)
+	go func() {
+		defer close(in)
+		for i := 0; i < 100; i++ {
+			if i == 50 {
+				// the first runs are spilled so stop
+				cancel()
+			}
+			in <- fmt.Sprint(i)
+		}
+	}()
+
+	x.ExternalSort{
+		Compare:  func(a, b interface{}) bool { return a.(string) <= b.(string) },
+		MaxCount: 10,
+		Dir:      dir,
+	}.TContext(ctx, in, out, errs)
+
+	if len(errs) > 0 {
+		t.Errorf("expected no error when cancelled got %v", <-errs)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) > 0 {
+		t.Errorf("expected the temp files to be removed got %d", len(files))
+	}
+}
+
+func TestExternalSort_noCompare(t *testing.T) {
+	in := make(chan interface{})
+	errs := make(chan error, 1)
+	go func() {
+		defer close(in)
+		for i := 0; i < 10; i++ {
+			in <- i
+		}
+	}()
+
+	x.ExternalSort{}.T(in, make(chan interface{}), errs)
+
+	if len(errs) != 1 {
+		t.Error("expected an error without a Compare func")
+	}
+}
@@ -1,6 +1,10 @@
 package x
 
-import "sync"
+import (
+	"sync"
+
+	"github.com/MasteryConnect/pipe/line"
+)
 
 /*
 Fanout takes one or more Tfunc's, and when a message is received on the 'in'
@@ -143,3 +147,12 @@ func (f *Fanout) initArrayChan(name string) {
 		f.chanLookup[name] = typeChan
 	}
 }
+
+// Describe implements the line.Describer interface
+func (f *Fanout) Describe() line.Node {
+	n := line.Node{Name: "x.Fanout", Concurrency: 1, Parallel: true}
+	for _, tfunc := range f.tfuncs {
+		n.Children = append(n.Children, line.Describe(tfunc))
+	}
+	return n
+}
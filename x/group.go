@@ -32,6 +32,16 @@ func (gm *GroupMsg) In() interface{} {
 	return gm.Batch
 }
 
+// GetBatch implements the message.Batcher interface
+func (gm *GroupMsg) GetBatch() message.Batch {
+	return gm.Batch
+}
+
+// WithBatch implements the message.Batcher interface
+func (gm *GroupMsg) WithBatch(b message.Batch) interface{} {
+	return &GroupMsg{Batch: b, Name: gm.Name}
+}
+
 // Group is the grouping transformer for pipe/line.
 type Group struct {
 	By   GroupByFunc
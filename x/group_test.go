@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	l "github.com/MasteryConnect/pipe/line"
+	"github.com/MasteryConnect/pipe/message"
 	"github.com/MasteryConnect/pipe/x"
 )
 
@@ -45,3 +46,14 @@ func ExampleGroup() {
 	// 7
 	// 9
 }
+
+func ExampleGroupMsg_getAll() {
+	r := message.NewRecord()
+	r.Set("id", 1)
+	msg := &x.GroupMsg{Name: "odd", Batch: message.Batch{r, "foo"}}
+
+	var recs []message.Record
+	message.GetAll(msg, &recs, message.IntoContainers)
+	fmt.Println(recs)
+	// Output: [{"id":1}]
+}
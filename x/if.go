@@ -96,3 +96,13 @@ func (i *If) T(inMsgs <-chan interface{}, outMsgs chan<- interface{}, errs chan<
 	// wait for everything to drain
 	wg.Wait()
 }
+
+// Describe implements the line.Describer interface
+func (i *If) Describe() l.Node {
+	n := l.Node{Name: "x.If", Concurrency: 1, Parallel: true}
+	n.Children = append(n.Children, l.Describe(i.Tfunc))
+	if i.Else != nil {
+		n.Children = append(n.Children, l.Describe(i.Else))
+	}
+	return n
+}
@@ -0,0 +1,311 @@
+package x
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+// Reshape ops
+const (
+	ReshapeSelect  = "select"  // keep only Fields in that order
+	ReshapeRename  = "rename"  // rename Field to To keeping its place
+	ReshapeDrop    = "drop"    // remove Fields
+	ReshapeDefault = "default" // set Field to Value if it is missing or nil
+	ReshapeConst   = "const"   // set Field to Value
+	ReshapeCast    = "cast"    // convert Field to Type (see message.Field)
+	ReshapeCompute = "compute" // set Field to the result of Template or Expr
+)
+
+// ReshapeOp is a single step of a Reshape.
+type ReshapeOp struct {
+	Op       string      `yaml:"op"`
+	Field    string      `yaml:"field"`
+	Fields   []string    `yaml:"fields"`
+	To       string      `yaml:"to"`
+	Value    interface{} `yaml:"value"`
+	Type     string      `yaml:"type"`
+	Layout   string      `yaml:"layout"`
+	Template string      `yaml:"template"` // a text/template with the record as the data
+	Expr     string      `yaml:"expr"`     // a small expression (see compileExpr)
+}
+
+// Reshape applies the ops in order to each record and sends on a new
+// *message.BasicRecord. Keys keep the order they had in the record and new
+// keys are added to the end so the output order is always the same.
+// Messages that aren't records or that an op fails on are sent to errs.
+type Reshape struct {
+	Ops []ReshapeOp `yaml:"ops"`
+
+	once  sync.Once
+	steps []reshapeStep
+	err   error
+}
+
+type reshapeStep func(r *message.BasicRecord) error
+
+// NewReshape creates a Reshape and checks the ops.
+func NewReshape(ops ...ReshapeOp) (*Reshape, error) {
+	r := &Reshape{Ops: ops}
+	return r, r.compile()
+}
+
+// NewReshapeYAML creates a Reshape from YAML like:
+//
+//	ops:
+//	  - {op: select, fields: [id, first, last]}
+//	  - {op: compute, field: name, expr: "first + ' ' + last"}
+func NewReshapeYAML(b []byte) (*Reshape, error) {
+	r := &Reshape{}
+	if err := yaml.Unmarshal(b, r); err != nil {
+		return nil, errors.Wrap(err, "reshape")
+	}
+	return r, r.compile()
+}
+
+// T implements the Tfunc interface
+func (rs *Reshape) T(in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
+	if err := rs.compile(); err != nil {
+		errs <- err
+		for range in {
+		}
+		return
+	}
+
+	for m := range in {
+		rec, err := rs.I(m)
+		if err != nil {
+			errs <- err
+		} else {
+			out <- rec
+		}
+	}
+}
+
+// I implements the InlineTfunc interface
+func (rs *Reshape) I(m interface{}) (interface{}, error) {
+	r, ok := m.(message.Record)
+	if !ok {
+		return nil, errors.Wrapf(ErrNotRecord, "got type %T", m)
+	}
+	return rs.Apply(r)
+}
+
+// Apply applies the ops to a copy of the record.
+func (rs *Reshape) Apply(r message.Record) (*message.BasicRecord, error) {
+	if err := rs.compile(); err != nil {
+		return nil, err
+	}
+
+	out := message.NewBasicRecord()
+	for _, k := range r.GetKeys() {
+		v, _ := r.Get(k)
+		out.Set(k, v)
+	}
+	for i, step := range rs.steps {
+		if err := step(out); err != nil {
+			return nil, errors.Wrapf(err, "reshape op %d (%s)", i, rs.Ops[i].Op)
+		}
+	}
+	return out, nil
+}
+
+func (rs *Reshape) compile() error {
+	rs.once.Do(func() {
+		for i, op := range rs.Ops {
+			step, err := op.compile()
+			if err != nil {
+				rs.err = errors.Wrapf(err, "reshape op %d (%s)", i, op.Op)
+				return
+			}
+			rs.steps = append(rs.steps, step)
+		}
+	})
+	return rs.err
+}
+
+func (op ReshapeOp) compile() (reshapeStep, error) {
+	fields := op.Fields
+	if op.Field != "" {
+		fields = append([]string{op.Field}, fields...)
+	}
+	needField := func() error {
+		if op.Field == "" {
+			return errors.New("missing field")
+		}
+		return nil
+	}
+
+	switch op.Op {
+	case ReshapeSelect:
+		return func(r *message.BasicRecord) error {
+			sel := message.NewBasicRecord()
+			for _, k := range fields {
+				if v, ok := r.Get(k); ok {
+					sel.Set(k, v)
+				}
+			}
+			*r = *sel
+			return nil
+		}, nil
+
+	case ReshapeDrop:
+		drop := map[string]bool{}
+		for _, k := range fields {
+			drop[k] = true
+		}
+		return func(r *message.BasicRecord) error {
+			kept := message.NewBasicRecord()
+			for i, k := range r.Keys {
+				if !drop[k] {
+					kept.Set(k, r.Vals[i])
+				}
+			}
+			*r = *kept
+			return nil
+		}, nil
+
+	case ReshapeRename:
+		if err := needField(); err != nil {
+			return nil, err
+		}
+		if op.To == "" {
+			return nil, errors.New("missing to")
+		}
+		return func(r *message.BasicRecord) error {
+			if _, ok := r.Get(op.Field); !ok || op.Field == op.To {
+				return nil
+			}
+			renamed := message.NewBasicRecord()
+			for i, k := range r.Keys {
+				switch k {
+				case op.To:
+					continue // replaced by the renamed field
+				case op.Field:
+					k = op.To
+				}
+				renamed.Set(k, r.Vals[i])
+			}
+			*r = *renamed
+			return nil
+		}, nil
+
+	case ReshapeDefault:
+		if err := needField(); err != nil {
+			return nil, err
+		}
+		return func(r *message.BasicRecord) error {
+			if v, _ := r.Get(op.Field); v == nil {
+				r.Set(op.Field, op.Value)
+			}
+			return nil
+		}, nil
+
+	case ReshapeConst:
+		if err := needField(); err != nil {
+			return nil, err
+		}
+		return func(r *message.BasicRecord) error {
+			r.Set(op.Field, op.Value)
+			return nil
+		}, nil
+
+	case ReshapeCast:
+		if err := needField(); err != nil {
+			return nil, err
+		}
+		switch op.Type {
+		case message.TypeString, message.TypeInt, message.TypeFloat, message.TypeBool,
+			message.TypeTime, message.TypeDecimal, message.TypeRecord, message.TypeAny:
+		default:
+			return nil, errors.Errorf("unknown type %q", op.Type)
+		}
+		f := message.Field{Name: op.Field, Type: op.Type, Layout: op.Layout, Nullable: true}
+		return func(r *message.BasicRecord) error {
+			v, ok := r.Get(op.Field)
+			if !ok {
+				return nil
+			}
+			val, err := f.Coerce(v)
+			if err != nil {
+				return err
+			}
+			r.Set(op.Field, val)
+			return nil
+		}, nil
+
+	case ReshapeCompute:
+		if err := needField(); err != nil {
+			return nil, err
+		}
+		return op.compileCompute()
+	}
+	return nil, errors.Errorf("unknown op %q", op.Op)
+}
+
+func (op ReshapeOp) compileCompute() (reshapeStep, error) {
+	switch {
+	case op.Expr != "" && op.Template != "":
+		return nil, errors.New("only one of expr or template can be set")
+
+	case op.Expr != "":
+		e, err := compileExpr(op.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return func(r *message.BasicRecord) error {
+			v, err := e(r)
+			if err != nil {
+				return err
+			}
+			r.Set(op.Field, v)
+			return nil
+		}, nil
+
+	case op.Template != "":
+		tmpl, err := template.New(op.Field).Funcs(template.FuncMap{
+			"upper": strings.ToUpper,
+			"lower": strings.ToLower,
+			"trim":  strings.TrimSpace,
+		}).Option("missingkey=zero").Parse(op.Template)
+		if err != nil {
+			return nil, err
+		}
+		return func(r *message.BasicRecord) error {
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, templateData(r)); err != nil {
+				return err
+			}
+			r.Set(op.Field, buf.String())
+			return nil
+		}, nil
+	}
+	return nil, errors.New("missing expr or template")
+}
+
+// templateData converts records to maps all the way down
+// so templates can use paths like {{.user.name}}.
+func templateData(v interface{}) interface{} {
+	switch val := v.(type) {
+	case message.Record:
+		data := map[string]interface{}{}
+		for _, k := range val.GetKeys() {
+			item, _ := val.Get(k)
+			data[k] = templateData(item)
+		}
+		return data
+	case []interface{}:
+		items := make([]interface{}, len(val))
+		for i, item := range val {
+			items[i] = templateData(item)
+		}
+		return items
+	}
+	return v
+}
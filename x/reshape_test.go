@@ -0,0 +1,141 @@
+package x_test
+
+import (
+	"fmt"
+	"testing"
+
+	l "github.com/MasteryConnect/pipe/line"
+	"github.com/MasteryConnect/pipe/message"
+	"github.com/MasteryConnect/pipe/x"
+)
+
+func ExampleReshape() {
+	reshape, err := x.NewReshape(
+		x.ReshapeOp{Op: x.ReshapeRename, Field: "fname", To: "first"},
+		x.ReshapeOp{Op: x.ReshapeCast, Field: "qty", Type: message.TypeInt},
+		x.ReshapeOp{Op: x.ReshapeCompute, Field: "total", Expr: "qty * price"},
+		x.ReshapeOp{Op: x.ReshapeCompute, Field: "label", Template: "{{upper .first}} x{{.qty}}"},
+		x.ReshapeOp{Op: x.ReshapeDefault, Field: "status", Value: "new"},
+		x.ReshapeOp{Op: x.ReshapeDrop, Fields: []string{"price"}},
+	)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	l.New().SetP(func(out chan<- interface{}, errs chan<- error) {
+		r := message.NewBasicRecord()
+		r.Set("fname", "ann")
+		r.Set("qty", "3")
+		r.Set("price", 2.5)
+		out <- r
+	}).Add(
+		reshape.T,
+		l.Stdout,
+	).Run()
+	// Output:
+	// {"first":"ann","qty":3,"total":7.5,"label":"ANN x3","status":"new"}
+}
+
+func ExampleNewReshapeYAML() {
+	reshape, err := x.NewReshapeYAML([]byte(`
+ops:
+  - {op: const, field: source, value: import}
+  - {op: compute, field: name, expr: "first + ' ' + last"}
+  - {op: select, fields: [id, name, source]}
+`))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	r := message.NewBasicRecord()
+	r.Set("first", "Ann")
+	r.Set("last", "Lee")
+	r.Set("id", 1)
+	out, err := reshape.Apply(r)
+	fmt.Println(out, err)
+	// Output:
+	// {"id":1,"name":"Ann Lee","source":"import"} <nil>
+}
+
+func TestReshape_errors(t *testing.T) {
+	if _, err := x.NewReshape(x.ReshapeOp{Op: "bogus"}); err == nil {
+		t.Error("expected an error for an unknown op")
+	}
+	if _, err := x.NewReshape(x.ReshapeOp{Op: x.ReshapeCompute, Field: "a", Expr: "1 +"}); err == nil {
+		t.Error("expected an error for a bad expression")
+	}
+	for _, src := range []string{"upper(a, b)", "if(a, b)", "trim()", "len(a, b)"} {
+		if _, err := x.NewReshape(x.ReshapeOp{Op: x.ReshapeCompute, Field: "a", Expr: src}); err == nil {
+			t.Errorf("%s: expected an error for the wrong number of args", src)
+		}
+	}
+	if _, err := x.NewReshape(x.ReshapeOp{Op: x.ReshapeCast, Field: "a", Type: "money"}); err == nil {
+		t.Error("expected an error for an unknown type")
+	}
+
+	reshape, err := x.NewReshape(x.ReshapeOp{Op: x.ReshapeCast, Field: "a", Type: message.TypeInt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reshape.I("not a record"); err == nil {
+		t.Error("expected an error for a non record")
+	}
+	r := message.NewBasicRecord()
+	r.Set("a", "one")
+	if _, err := reshape.Apply(r); err == nil {
+		t.Error("expected a cast error")
+	}
+}
+
+func TestReshape_expr(t *testing.T) {
+	r := message.NewBasicRecord()
+	r.Set("a", 7)
+	r.Set("b", 2)
+	r.Set("s", " Hi ")
+	r.Set("d", message.Decimal("12345678901234567.89"))
+	r.Set("f", 0.5)
+	user := message.NewBasicRecord()
+	user.Set("name", "ann")
+	r.Set("user", user)
+
+	cases := map[string]interface{}{
+		"a + b * 2":              int64(11),
+		"(a + b) * 2":            int64(18),
+		"a / b":                  int64(3),
+		"a % b":                  int64(1),
+		"a / 2.0":                message.Decimal("3.5"),
+		"0.1 + 0.2":              message.Decimal("0.3"),
+		"d * 3":                  message.Decimal("37037036703703703.67"),
+		"d - 0.09 > d - 0.1":     true,
+		"1.0 / 3":                message.Decimal("0.3333333333333333"),
+		"0.1 + 0.2 == 0.3":       true,
+		"f * 2.0":                1.0,
+		"-a + 1":                 int64(-6),
+		"a > b && b >= 2":        true,
+		"!(a == 7) || missing":   false,
+		"upper(user.name)":       "ANN",
+		"len(trim(s))":           int64(2),
+		"coalesce(missing, 'x')": "x",
+		"concat(a, '-', b)":      "7-2",
+		"if(a < b, 'lt', 'ge')":  "ge",
+		`"a" + a`:                "a7",
+		"missing == null":        true,
+	}
+	for src, want := range cases {
+		reshape, err := x.NewReshape(x.ReshapeOp{Op: x.ReshapeCompute, Field: "out", Expr: src})
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+			continue
+		}
+		out, err := reshape.Apply(r)
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+			continue
+		}
+		if got, _ := out.Get("out"); got != want {
+			t.Errorf("%s: expected %v (%T) got %v (%T)", src, want, want, got, got)
+		}
+	}
+}
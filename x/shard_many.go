@@ -103,3 +103,12 @@ func (sm *ShardMany) wgT(in <-chan interface{}, out chan<- interface{}, errs cha
 	defer sm.wg.Done()
 	sm.tfunc(in, out, errs)
 }
+
+// Describe implements the line.Describer interface
+func (sm *ShardMany) Describe() l.Node {
+	return l.Node{
+		Name:        "x.ShardMany",
+		Concurrency: sm.concurrency,
+		Children:    []l.Node{l.Describe(sm.tfunc)},
+	}
+}
@@ -6,7 +6,7 @@ import "fmt"
 // There is no guarantee of exact ordering, but better than nothing.
 type Sort struct {
 	N       int
-	Compare func(x, y interface{}) bool // true if x <= y
+	Compare func(x, y interface{}) bool // true if x <= y (see message.CompareBy for records)
 }
 
 // T is the Tfunc for Sort.
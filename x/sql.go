@@ -15,6 +15,8 @@ type SQL struct {
 	MaskKeys []string // useful for logging without sensitive values like passwords
 
 	NumberArgs bool // use $1 style placeholders for the resulting queries
+
+	Dialect message.Dialect // optional dialect for the placeholders and quoting
 }
 
 // ErrSQLTypeConversionError is the error
@@ -62,7 +64,7 @@ func (s SQL) SQLInsertFromBatch(v message.Batch) (*message.Query, error) {
 			}
 			qs := make([]string, len(v.GetVals()))
 			for i := range qs {
-				qs[i] = "?"
+				qs[i] = "?" // converted for the dialect by the query
 			}
 			rowPlaceholders = append(rowPlaceholders, "("+strings.Join(qs, ",")+")")
 			vals = append(vals, v.GetVals()...)
@@ -70,10 +72,22 @@ func (s SQL) SQLInsertFromBatch(v message.Batch) (*message.Query, error) {
 			return nil, errors.Wrapf(ErrSQLTypeConversionError, "got type %T", m)
 		}
 	}
+	cols := make([]string, len(keys))
+	for i, k := range keys {
+		cols[i] = s.quote(k)
+	}
 	return &message.Query{SQL: fmt.Sprintf(
 		sql,
-		table,
-		strings.Join(keys, ","),
+		s.quote(table),
+		strings.Join(cols, ","),
 		strings.Join(rowPlaceholders, ","),
-	), Args: vals, NumberArgs: s.NumberArgs}, nil
+	), Args: vals, NumberArgs: s.NumberArgs, Dialect: s.Dialect}, nil
+}
+
+// quote quotes the identifier if there is a dialect.
+func (s SQL) quote(name string) string {
+	if s.Dialect == nil {
+		return name
+	}
+	return s.Dialect.QuoteIdent(name)
 }
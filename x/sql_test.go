@@ -70,3 +70,24 @@ func TestSQL_I(t *testing.T) {
 		t.Error(ssql)
 	}
 }
+
+func ExampleSQL_T_dialect() {
+	l.New().SetP(func(out chan<- interface{}, errs chan<- error) {
+		r := message.NewRecord()
+		r.Set("order", 1)
+		r.Set("name", "it's")
+		out <- r
+	}).Add(
+		x.SQL{Table: "foo", Dialect: message.PostgreSQL}.T,
+		l.I(func(m interface{}) (interface{}, error) {
+			sql, _ := m.(message.ToSQLer).ToSQL()
+			fmt.Println(sql)
+			return m, nil
+		}),
+		l.Stdout,
+	).Run()
+
+	// Output:
+	// INSERT INTO "foo" ("order","name") VALUES ($1,$2)
+	// INSERT INTO "foo" ("order","name") VALUES (1,'it''s')
+}
@@ -0,0 +1,149 @@
+package x
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+// Record is a tap that writes every message passing through it to a tape
+// along with its type and the time it passed. The messages are sent on
+// untouched. Use Replay to send the messages from the tape again later.
+//
+// The tape is a JSON document per line so it can be inspected and edited.
+// The messages are encoded with message.JSONCodec so their types need to be
+// registered (see message.Register). Other messages are passed on with an error.
+type Record struct {
+	Path   string    // the tape file to create
+	Writer io.Writer // write the tape here instead of to a file at Path
+}
+
+// T implements the Tfunc interface
+func (r Record) T(in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
+	w := r.Writer
+	if w == nil {
+		file, err := os.Create(r.Path)
+		if err != nil {
+			errs <- err
+			pass(in, out)
+			return
+		}
+		defer func() {
+			if err := file.Close(); err != nil {
+				errs <- err
+			}
+		}()
+		w = file
+	}
+
+	enc := json.NewEncoder(w)
+	for m := range in {
+		if err := writeTape(enc, time.Now(), m); err != nil {
+			errs <- err
+		}
+		out <- m
+	}
+}
+
+// pass sends all the messages on untouched.
+func pass(in <-chan interface{}, out chan<- interface{}) {
+	for m := range in {
+		out <- m
+	}
+}
+
+// Replay is a producer that sends the messages from a tape written by Record.
+// By default the messages are sent with the same timing they were recorded
+// with. Speed will replay them faster (or slower) and NoWait as fast as possible.
+type Replay struct {
+	Path   string    // the tape file to read
+	Reader io.Reader // read the tape from here instead of from a file at Path
+	Speed  float64   // 2 replays twice as fast, 0.5 half as fast (0 is the same as 1)
+	NoWait bool      // don't wait between messages
+}
+
+// P implements the Pfunc interface
+func (r Replay) P(out chan<- interface{}, errs chan<- error) {
+	r.PContext(context.Background(), out, errs)
+}
+
+// PContext implements the PfuncContext interface.
+// The replay stops when the context is done.
+func (r Replay) PContext(ctx context.Context, out chan<- interface{}, errs chan<- error) {
+	rd := r.Reader
+	if rd == nil {
+		file, err := os.Open(r.Path)
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer file.Close()
+		rd = file
+	}
+
+	speed := r.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+
+	dec := json.NewDecoder(rd)
+	var prev time.Time
+	for n := 1; ; n++ {
+		var entry tapeEntry
+		if err := dec.Decode(&entry); err == io.EOF {
+			return
+		} else if err != nil {
+			errs <- errors.Wrapf(err, "tape: entry %d", n)
+			return // can't find the next entry in a broken tape
+		}
+
+		m, err := entry.decode()
+		if err != nil {
+			errs <- errors.Wrapf(err, "tape: entry %d", n)
+			continue
+		}
+
+		if !r.NoWait && !prev.IsZero() {
+			wait := time.Duration(float64(entry.Time.Sub(prev)) / speed)
+			if wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+		prev = entry.Time
+
+		select {
+		case out <- m:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// tapeEntry is a single message on the tape. The message is encoded
+// with message.JSONCodec so it is decoded as the same type.
+type tapeEntry struct {
+	Time time.Time       `json:"time"`
+	Msg  json.RawMessage `json:"msg"`
+}
+
+func writeTape(enc *json.Encoder, t time.Time, m interface{}) error {
+	raw, err := message.Marshal(message.JSONCodec, m)
+	if err != nil {
+		return errors.Wrap(err, "tape")
+	}
+	return enc.Encode(tapeEntry{Time: t, Msg: raw})
+}
+
+func (e tapeEntry) decode() (interface{}, error) {
+	return message.Unmarshal(message.JSONCodec, e.Msg)
+}
@@ -0,0 +1,199 @@
+package x_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	l "github.com/MasteryConnect/pipe/line"
+	"github.com/MasteryConnect/pipe/message"
+	"github.com/MasteryConnect/pipe/x"
+)
+
+type delta interface {
+	GetSQL() string
+	GetArgs() []interface{}
+}
+
+func TestRecord_replay(t *testing.T) {
+	rec := message.NewRecord()
+	rec.Set("id", 1)
+	rec.Set("name", "foo")
+	rec.Set("when", time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC))
+
+	idRec := message.NewIDRecord("id")
+	idRec.Set("id", int64(2))
+	idRec.Set("score", 1.5)
+
+	update := message.NewUpdateDelta(idRec, "bars")
+	update.Changes.(message.MutableRecord).Set("score", 1.0)
+
+	msgs := []interface{}{
+		"foo",
+		[]byte("bar"),
+		rec,
+		message.Batch{"a", rec, nil},
+		message.Event{Timestamp: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Source: "log", Message: "hi"},
+		message.Query{SQL: "SELECT * FROM foo WHERE id = ?", Args: []interface{}{uint8(1)}, NumberArgs: true},
+		&message.Query{SQL: "SELECT 1"},
+		message.NewInsertDelta(rec, "foos"),
+		update,
+		message.NewDeleteDelta(idRec, "bars"),
+		message.UpsertDelta{IDRecord: idRec, Table: "bars"},
+		message.Envelope{ID: "e1", Headers: map[string]string{"k": "v"}, Payload: "hi"},
+		message.Decimal("12345678901234567890.123456789"),
+	}
+
+	var tape bytes.Buffer
+	var passed []interface{}
+	l.New().SetP(func(out chan<- interface{}, errs chan<- error) {
+		for _, m := range msgs {
+			out <- m
+		}
+	}).Add(
+		x.Record{Writer: &tape}.T,
+	).SetC(func(in <-chan interface{}, errs chan<- error) {
+		for m := range in {
+			passed = append(passed, m)
+		}
+	}).Run()
+
+	if !reflect.DeepEqual(passed, msgs) {
+		t.Fatalf("want the messages passed on untouched got %v", passed)
+	}
+	if lines := strings.Count(tape.String(), "\n"); lines != len(msgs) {
+		t.Fatalf("want %d lines on the tape got %d", len(msgs), lines)
+	}
+
+	var replayed []interface{}
+	l.New().SetP(
+		x.Replay{Reader: &tape, NoWait: true}.P,
+	).SetC(func(in <-chan interface{}, errs chan<- error) {
+		for m := range in {
+			replayed = append(replayed, m)
+		}
+	}).Run()
+
+	if len(replayed) != len(msgs) {
+		t.Fatalf("want %d messages replayed got %d", len(msgs), len(replayed))
+	}
+	for i := range msgs {
+		want, got := msgs[i], replayed[i]
+		if reflect.TypeOf(want) != reflect.TypeOf(got) {
+			t.Errorf("%d: want type %T got %T", i, want, got)
+			continue
+		}
+		if d, ok := want.(delta); ok {
+			if d.GetSQL() != got.(delta).GetSQL() || !reflect.DeepEqual(d.GetArgs(), got.(delta).GetArgs()) {
+				t.Errorf("%d: want %v got %v", i, want, got)
+			}
+		} else if message.String(want) != message.String(got) {
+			t.Errorf("%d: want %v got %v", i, want, got)
+		}
+	}
+
+	if got := replayed[2].(*message.BasicRecord).GetKeys(); !reflect.DeepEqual(got, []string{"id", "name", "when"}) {
+		t.Errorf("want the key order kept got %v", got)
+	}
+	if v, _ := replayed[2].(*message.BasicRecord).Get("when"); !v.(time.Time).Equal(rec.(*message.BasicRecord).Vals[2].(time.Time)) {
+		t.Errorf("want the time kept got %v", v)
+	}
+	if got := replayed[5].(message.Query).Args[0]; got != uint8(1) {
+		t.Errorf("want the arg type kept got %T", got)
+	}
+	if got := replayed[8].(*message.UpdateDelta).Changes; message.String(got) != `{"score":1}` {
+		t.Errorf("want the changes kept got %v", got)
+	}
+	if got := replayed[9].(*message.DeleteDelta).GetSQL(); got != "DELETE FROM bars WHERE id=?" {
+		t.Errorf("want the delete delta got %s", got)
+	}
+}
+
+func TestReplay_speed(t *testing.T) {
+	tape := `{"time":"2020-01-01T00:00:00Z","msg":{"t":"string","s":"a"}}
+{"time":"2020-01-01T00:00:01Z","msg":{"t":"string","s":"b"}}
+{"time":"2020-01-01T00:00:02Z","msg":{"t":"int","i":3}}
+`
+	var replayed []interface{}
+	start := time.Now()
+	l.New().SetP(
+		x.Replay{Reader: strings.NewReader(tape), Speed: 20}.P,
+	).SetC(func(in <-chan interface{}, errs chan<- error) {
+		for m := range in {
+			replayed = append(replayed, m)
+		}
+	}).Run()
+	elapsed := time.Since(start)
+
+	if !reflect.DeepEqual(replayed, []interface{}{"a", "b", 3}) {
+		t.Errorf("want a b 3 got %v", replayed)
+	}
+	if elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Errorf("want 2s replayed 20x faster got %v", elapsed)
+	}
+}
+
+func TestReplay_badEntry(t *testing.T) {
+	tape := `{"time":"2020-01-01T00:00:00Z","msg":{"t":"string","s":"a"}}
+{"time":"2020-01-01T00:00:00Z","msg":{"t":"foo.Bar"}}
+{"time":"2020-01-01T00:00:00Z","msg":{"t":"string","s":"c"}}
+`
+	var replayed []interface{}
+	var errList []error
+	errs := make(chan error)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for err := range errs {
+			errList = append(errList, err)
+		}
+	}()
+
+	l.New().SetP(
+		x.Replay{Reader: strings.NewReader(tape)}.P,
+	).SetC(func(in <-chan interface{}, errs chan<- error) {
+		for m := range in {
+			replayed = append(replayed, m)
+		}
+	}).SetErrs(errs).Run()
+	close(errs)
+	<-done
+
+	if !reflect.DeepEqual(replayed, []interface{}{"a", "c"}) {
+		t.Errorf("want a c got %v", replayed)
+	}
+	if len(errList) != 1 || !strings.Contains(errList[0].Error(), "entry 2") {
+		t.Errorf("want an error for entry 2 got %v", errList)
+	}
+}
+
+func TestRecord_dialect(t *testing.T) {
+	rec := message.NewRecord()
+	rec.Set("order", 1)
+	insert := message.NewInsertDelta(rec, "t")
+	insert.Dialect = message.PostgreSQL
+
+	var tape bytes.Buffer
+	l.New().SetP(func(out chan<- interface{}, errs chan<- error) {
+		out <- insert
+	}).Add(x.Record{Writer: &tape}.T).Run()
+
+	var replayed []interface{}
+	l.New().SetP(
+		x.Replay{Reader: &tape, NoWait: true}.P,
+	).SetC(func(in <-chan interface{}, errs chan<- error) {
+		for m := range in {
+			replayed = append(replayed, m)
+		}
+	}).Run()
+
+	if len(replayed) != 1 {
+		t.Fatalf("want the delta replayed got %v", replayed)
+	}
+	want := `INSERT INTO "t" ("order") VALUES ($1)`
+	if got := replayed[0].(*message.InsertDelta).GetSQL(); got != want {
+		t.Errorf("want '%s' got '%s'", want, got)
+	}
+}
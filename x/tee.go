@@ -11,33 +11,49 @@ import (
 //	otherpipe := line.New()
 //	line.New().Add(x.Tee(otherpipe)).Run()
 func Tee(targets ...line.Tfunc) line.Tfunc {
-	return func(in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
-		var wg sync.WaitGroup
-		wg.Add(len(targets))
-		inChans := make([]chan interface{}, len(targets))
-
-		for i, t := range targets {
-			inChans[i] = make(chan interface{})
-			go func(tin <-chan interface{}, target line.Tfunc) {
-				defer wg.Done()
-				target(tin, out, errs) // tee up the target
-			}(inChans[i], t)
-		}
+	return Tees(targets).T
+}
 
-		// now pass the messages along to all targets and downstream
-		for m := range in {
-			for _, targetIn := range inChans {
-				targetIn <- m
-			}
-			out <- m
-		}
+// Tees is the Transformer version of Tee. Use it with AddTransformer
+// so the targets are included when the pipeline is described.
+type Tees []line.Tfunc
+
+// T implements the line.Transformer interface
+func (targets Tees) T(in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
+	var wg sync.WaitGroup
+	wg.Add(len(targets))
+	inChans := make([]chan interface{}, len(targets))
+
+	for i, t := range targets {
+		inChans[i] = make(chan interface{})
+		go func(tin <-chan interface{}, target line.Tfunc) {
+			defer wg.Done()
+			target(tin, out, errs) // tee up the target
+		}(inChans[i], t)
+	}
 
-		// our in is done so close the other ins
-		for _, c := range inChans {
-			close(c)
+	// now pass the messages along to all targets and downstream
+	for m := range in {
+		for _, targetIn := range inChans {
+			targetIn <- m
 		}
+		out <- m
+	}
+
+	// our in is done so close the other ins
+	for _, c := range inChans {
+		close(c)
+	}
+
+	// wait for all the targets to finish now that their 'in's are closed
+	wg.Wait()
+}
 
-		// wait for all the targets to finish now that their 'in's are closed
-		wg.Wait()
+// Describe implements the line.Describer interface
+func (targets Tees) Describe() line.Node {
+	n := line.Node{Name: "x.Tee", Concurrency: 1, Parallel: true}
+	for _, t := range targets {
+		n.Children = append(n.Children, line.Describe(t))
 	}
+	return n
 }
@@ -0,0 +1,61 @@
+package x
+
+import "github.com/MasteryConnect/pipe/message"
+
+// Unbatch flattens batches back into the individual messages.
+// The messages of a GroupMsg are sent as a *GroupItem so the
+// name of the group is kept. Other messages are passed on as is.
+type Unbatch struct {
+	Deep bool // also flatten any batches inside of the batches
+}
+
+// GroupItem is a message that came from a GroupMsg.
+type GroupItem struct {
+	M     interface{}
+	Group string // the name of the group the message was in
+}
+
+// In implements Inner for message.Get
+func (gi *GroupItem) In() interface{} {
+	return gi.M
+}
+
+// String implements the fmt.Stringer interface
+func (gi *GroupItem) String() string {
+	return message.String(gi.M)
+}
+
+// T implements the Tfunc interface
+func (u Unbatch) T(in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
+	for m := range in {
+		u.flatten(m, "", true, out)
+	}
+}
+
+// flatten sends the messages of the batch on with the group they are in.
+func (u Unbatch) flatten(m interface{}, group string, top bool, out chan<- interface{}) {
+	if top || u.Deep {
+		switch v := m.(type) {
+		case *GroupMsg:
+			for _, item := range v.Batch {
+				u.flatten(item, v.Name, false, out)
+			}
+			return
+		case message.Batch:
+			for _, item := range v {
+				u.flatten(item, group, false, out)
+			}
+			return
+		case message.Batcher:
+			for _, item := range v.GetBatch() {
+				u.flatten(item, group, false, out)
+			}
+			return
+		}
+	}
+
+	if group != "" {
+		m = &GroupItem{M: m, Group: group}
+	}
+	out <- m
+}
@@ -0,0 +1,34 @@
+package x_test
+
+import (
+	"fmt"
+
+	l "github.com/MasteryConnect/pipe/line"
+	"github.com/MasteryConnect/pipe/message"
+	"github.com/MasteryConnect/pipe/x"
+)
+
+func ExampleUnbatch() {
+	l.New().SetP(func(out chan<- interface{}, errs chan<- error) {
+		out <- message.Batch{"a", "b"}
+		out <- &x.GroupMsg{Name: "odd", Batch: message.Batch{1, message.Batch{3, 5}}}
+		out <- "c"
+	}).Add(
+		x.Unbatch{Deep: true}.T,
+	).SetC(func(in <-chan interface{}, errs chan<- error) {
+		for m := range in {
+			if gi, ok := m.(*x.GroupItem); ok {
+				fmt.Println(gi.Group, gi)
+			} else {
+				fmt.Println(m)
+			}
+		}
+	}).Run()
+	// Output:
+	// a
+	// b
+	// odd 1
+	// odd 3
+	// odd 5
+	// c
+}
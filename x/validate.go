@@ -0,0 +1,46 @@
+package x
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/MasteryConnect/pipe/message"
+)
+
+// ErrNotRecord is the error for a message that needs to be a record.
+var ErrNotRecord = errors.New("message is not a record")
+
+// Validate coerces each record to the schema and sends it on.
+// Invalid records are sent to the errs channel as a *message.ValidationError
+// with the reason each field is invalid. IDRecords keep their ID keys.
+type Validate struct {
+	Schema *message.Schema
+}
+
+// T implements the Tfunc interface
+func (v Validate) T(in <-chan interface{}, out chan<- interface{}, errs chan<- error) {
+	for m := range in {
+		rec, err := v.I(m)
+		if err != nil {
+			errs <- err
+		} else {
+			out <- rec
+		}
+	}
+}
+
+// I implements the InlineTfunc interface
+func (v Validate) I(m interface{}) (interface{}, error) {
+	r, ok := m.(message.Record)
+	if !ok {
+		return nil, errors.Wrapf(ErrNotRecord, "got type %T", m)
+	}
+
+	rec, err := v.Schema.Coerce(r)
+	if err != nil {
+		return nil, err
+	}
+	if idr, ok := r.(message.IDRecord); ok {
+		return &message.BasicIDRecord{MutableRecord: rec, IDKeys: idr.GetIDKeys()}, nil
+	}
+	return rec, nil
+}
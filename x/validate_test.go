@@ -0,0 +1,30 @@
+package x_test
+
+import (
+	"fmt"
+
+	l "github.com/MasteryConnect/pipe/line"
+	"github.com/MasteryConnect/pipe/message"
+	"github.com/MasteryConnect/pipe/x"
+)
+
+func ExampleValidate() {
+	errs := make(chan error, 1)
+
+	l.New().SetP(func(out chan<- interface{}, errs chan<- error) {
+		out <- message.NewRecordFromMSI(map[string]interface{}{"id": "1"})
+		out <- message.NewRecordFromMSI(map[string]interface{}{"id": "one"})
+	}).Add(
+		x.Validate{Schema: &message.Schema{Fields: []message.Field{
+			{Name: "id", Type: message.TypeInt},
+			{Name: "name", Type: message.TypeString, Default: "unknown"},
+		}}}.T,
+		x.SQL{Table: "foo"}.T,
+		l.Stdout,
+	).SetErrs(errs).Run()
+
+	fmt.Println(<-errs)
+	// Output:
+	// INSERT INTO foo (id,name) VALUES ('1','unknown')
+	// invalid record: id: can't convert "one" to an int
+}